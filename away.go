@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cli/safeexec"
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// clock abstracts time so that the away daemon can be driven by a fake one.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// idleSource reports how long the user has been inactive.
+type idleSource interface {
+	IdleTime() (time.Duration, error)
+}
+
+// xIdleSource asks xprintidle for the X11 idle time.
+type xIdleSource struct {
+	bin string
+}
+
+func (s xIdleSource) IdleTime() (time.Duration, error) {
+	out, err := exec.Command(s.bin).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run xprintidle: %w", err)
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected xprintidle output %q", out)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// mutterIdleSource asks GNOME's Mutter over D-Bus for the idle time, which
+// works under Wayland where xprintidle can't see input.
+type mutterIdleSource struct {
+	bin string
+}
+
+var mutterIdleRE = regexp.MustCompile(`uint64 (\d+)`)
+
+func (s mutterIdleSource) IdleTime() (time.Duration, error) {
+	out, err := exec.Command(s.bin, "call", "--session",
+		"--dest", "org.gnome.Mutter.IdleMonitor",
+		"--object-path", "/org/gnome/Mutter/IdleMonitor/Core",
+		"--method", "org.gnome.Mutter.IdleMonitor.GetIdletime").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query Mutter idle monitor: %w", err)
+	}
+	m := mutterIdleRE.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected Mutter idle monitor output %q", out)
+	}
+	ms, _ := strconv.ParseInt(string(m[1]), 10, 64)
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// fileIdleSource treats the time since a file was last modified as the idle
+// time, for setups where something else touches the file on activity.
+type fileIdleSource struct {
+	path  string
+	clock clock
+}
+
+func (s fileIdleSource) IdleTime() (time.Duration, error) {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return 0, err
+	}
+	return s.clock.Now().Sub(fi.ModTime()), nil
+}
+
+// detectIdleSource picks the first idle source that works in this session.
+func detectIdleSource() (idleSource, error) {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if bin, err := safeexec.LookPath("gdbus"); err == nil {
+			s := mutterIdleSource{bin: bin}
+			if _, err := s.IdleTime(); err == nil {
+				return s, nil
+			}
+		}
+	}
+	if os.Getenv("DISPLAY") != "" {
+		if bin, err := safeexec.LookPath("xprintidle"); err == nil {
+			return xIdleSource{bin: bin}, nil
+		}
+	}
+	return nil, errors.New("could not detect idle time; install xprintidle, run GNOME, or use --file")
+}
+
+// statusSetter is how the away daemon reads and changes the status.
+type statusSetter interface {
+	Current() (*status.Status, error)
+	Set(opts setOptions) error
+	Restore(prev *status.Status) error
+}
+
+// ghStatusSetter changes the status through the same path as the set command.
+type ghStatusSetter struct{}
+
+func (ghStatusSetter) Current() (*status.Status, error)  { return apiStatus("") }
+func (ghStatusSetter) Set(opts setOptions) error         { return runSet(opts) }
+func (ghStatusSetter) Restore(prev *status.Status) error { return restoreStatus(prev) }
+
+// awayDaemon sets an away status once the user has been idle for After and
+// restores the previous status when they come back.
+type awayDaemon struct {
+	After    time.Duration
+	Interval time.Duration
+	Away     setOptions
+	Idle     idleSource
+	Clock    clock
+	Status   statusSetter
+
+	away bool
+	prev *status.Status
+}
+
+// Step checks the idle time once and changes the status if needed.
+func (d *awayDaemon) Step() error {
+	idle, err := d.Idle.IdleTime()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !d.away && idle >= d.After:
+		prev, err := d.Status.Current()
+		if err != nil {
+			return err
+		}
+		if err := d.Status.Set(d.Away); err != nil {
+			return err
+		}
+		d.prev = prev
+		d.away = true
+	case d.away && idle < d.After:
+		return d.comeBack()
+	}
+
+	return nil
+}
+
+// comeBack restores the previous status, unless the away status was replaced
+// in the meantime, for example from another machine.
+func (d *awayDaemon) comeBack() error {
+	cur, err := d.Status.Current()
+	if err != nil {
+		return err
+	}
+	d.away = false
+	if cur == nil || cur.Message != d.Away.Message || cur.Emoji != fmt.Sprintf(":%s:", d.Away.Emoji) {
+		return nil
+	}
+	return d.Status.Restore(d.prev)
+}
+
+// Run calls Step every Interval until ctx is done, then restores the previous
+// status if the user is still marked away. Errors from Step are passed to
+// onError rather than stopping the daemon.
+func (d *awayDaemon) Run(ctx context.Context, onError func(error)) error {
+	for {
+		if err := d.Step(); err != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if d.away {
+				return d.comeBack()
+			}
+			return nil
+		case <-d.Clock.After(d.Interval):
+		}
+	}
+}
+
+type autoAwayOptions struct {
+	After    time.Duration
+	Interval time.Duration
+	File     string
+	Message  string
+	Emoji    string
+	Limited  bool
+}
+
+func autoAwayCmd() *cobra.Command {
+	opts := autoAwayOptions{}
+	cmd := &cobra.Command{
+		Use:   "auto-away",
+		Short: "set an away status while you are idle",
+		Long: `Run until interrupted, setting an away status once you have been idle for a
+while and restoring your previous status when you come back.
+
+Idle time is read from GNOME's idle monitor under Wayland or from xprintidle
+under X11. Elsewhere, use --file to treat the time since a file was last
+modified as the idle time.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Interval <= 0 {
+				return errors.New("interval must be positive")
+			}
+			return runAutoAway(opts)
+		},
+	}
+	cmd.Flags().DurationVarP(&opts.After, "after", "a", 30*time.Minute, "How long to be idle before going away")
+	cmd.Flags().DurationVarP(&opts.Interval, "interval", "i", 30*time.Second, "How often to check the idle time")
+	cmd.Flags().StringVarP(&opts.File, "file", "f", "", "Use the modification time of this file as the last activity")
+	cmd.Flags().StringVarP(&opts.Message, "message", "m", "away", "Status to show while idle")
+	cmd.Flags().StringVarP(&opts.Emoji, "emoji", "e", "zzz", "Emoji to show while idle")
+	cmd.Flags().BoolVarP(&opts.Limited, "limited", "l", true, "Indicate limited availability while idle")
+
+	return cmd
+}
+
+func runAutoAway(opts autoAwayOptions) error {
+	var idle idleSource
+	if opts.File != "" {
+		idle = fileIdleSource{path: opts.File, clock: realClock{}}
+	} else {
+		var err error
+		idle, err = detectIdleSource()
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := &awayDaemon{
+		After:    opts.After,
+		Interval: opts.Interval,
+		Away: setOptions{
+			Message: opts.Message,
+			Emoji:   opts.Emoji,
+			Limited: opts.Limited,
+		},
+		Idle:   idle,
+		Clock:  realClock{},
+		Status: ghStatusSetter{},
+	}
+
+	return d.Run(ctx, func(err error) {
+		fmt.Fprintf(os.Stderr, "%s %s\n", timestamp(), err)
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// fakeClock moves forward only when waited on.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// scriptedIdle reports each idle time in turn, calling done once it runs out
+// and repeating the last one from then on.
+type scriptedIdle struct {
+	idles []time.Duration
+	done  func()
+}
+
+func (s *scriptedIdle) IdleTime() (time.Duration, error) {
+	idle := s.idles[0]
+	if len(s.idles) > 1 {
+		s.idles = s.idles[1:]
+	} else if s.done != nil {
+		s.done()
+	}
+	return idle, nil
+}
+
+// fakeStatusSetter keeps the status in memory and records each change.
+type fakeStatusSetter struct {
+	current *status.Status
+	changes []string
+}
+
+func (f *fakeStatusSetter) Current() (*status.Status, error) { return f.current, nil }
+
+func (f *fakeStatusSetter) Set(opts setOptions) error {
+	f.current = &status.Status{Message: opts.Message, Emoji: fmt.Sprintf(":%s:", opts.Emoji)}
+	f.changes = append(f.changes, "set "+opts.Message)
+	return nil
+}
+
+func (f *fakeStatusSetter) Restore(prev *status.Status) error {
+	f.current = prev
+	f.changes = append(f.changes, "restore "+prev.Message)
+	return nil
+}
+
+func newTestAwayDaemon(idle idleSource, setter *fakeStatusSetter) *awayDaemon {
+	return &awayDaemon{
+		After:    30 * time.Minute,
+		Interval: 30 * time.Second,
+		Away:     setOptions{Message: "away", Emoji: "zzz"},
+		Idle:     idle,
+		Clock:    &fakeClock{now: time.Date(2021, 6, 4, 9, 0, 0, 0, time.UTC)},
+		Status:   setter,
+	}
+}
+
+func TestAwayDaemonSetsAndRestores(t *testing.T) {
+	setter := &fakeStatusSetter{current: &status.Status{Message: "reviewing", Emoji: ":eyes:"}}
+	idle := &scriptedIdle{idles: []time.Duration{
+		time.Minute, 29 * time.Minute, 30 * time.Minute, 45 * time.Minute, 5 * time.Second, time.Minute,
+	}}
+	d := newTestAwayDaemon(idle, setter)
+
+	for i := 0; i < 6; i++ {
+		if err := d.Step(); err != nil {
+			t.Fatalf("step %d: unexpected error: %s", i, err)
+		}
+	}
+
+	want := []string{"set away", "restore reviewing"}
+	if !reflect.DeepEqual(setter.changes, want) {
+		t.Errorf("got changes %q, want %q", setter.changes, want)
+	}
+	if d.away {
+		t.Error("expected the daemon to be back")
+	}
+}
+
+func TestAwayDaemonLeavesReplacedStatus(t *testing.T) {
+	setter := &fakeStatusSetter{current: &status.Status{Message: "reviewing", Emoji: ":eyes:"}}
+	idle := &scriptedIdle{idles: []time.Duration{time.Hour, 0}}
+	d := newTestAwayDaemon(idle, setter)
+
+	if err := d.Step(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// Set from another machine while away.
+	setter.current = &status.Status{Message: "on a train", Emoji: ":train:"}
+	if err := d.Step(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"set away"}; !reflect.DeepEqual(setter.changes, want) {
+		t.Errorf("got changes %q, want %q", setter.changes, want)
+	}
+	if setter.current.Message != "on a train" {
+		t.Errorf("expected the new status to be kept, got %+v", setter.current)
+	}
+}
+
+func TestAwayDaemonRestoresWhenStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setter := &fakeStatusSetter{current: &status.Status{Message: "reviewing", Emoji: ":eyes:"}}
+	idle := &scriptedIdle{idles: []time.Duration{0, time.Hour}, done: cancel}
+	d := newTestAwayDaemon(idle, setter)
+
+	err := d.Run(ctx, func(err error) {
+		t.Errorf("unexpected error: %s", err)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{"set away", "restore reviewing"}
+	if !reflect.DeepEqual(setter.changes, want) {
+		t.Errorf("got changes %q, want %q", setter.changes, want)
+	}
+}
+
+func TestFileIdleSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	touched := time.Date(2021, 6, 4, 9, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, touched, touched); err != nil {
+		t.Fatal(err)
+	}
+
+	s := fileIdleSource{path: path, clock: &fakeClock{now: touched.Add(12 * time.Minute)}}
+	idle, err := s.IdleTime()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if idle != 12*time.Minute {
+		t.Errorf("got %s, want 12m", idle)
+	}
+}
+
+func TestAutoAwayRejectsNonPositiveInterval(t *testing.T) {
+	f := setupTest(t)
+	_, err := runCommand(t, "auto-away", "--interval", "0")
+	if err == nil || err.Error() != "interval must be positive" {
+		t.Errorf("expected the interval to be rejected, got %v", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("expected no requests, got %q", f.calls)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// cacheDir returns the directory the extension keeps cached API responses in,
+// creating it if needed. GH_USER_STATUS_CACHE_DIR overrides the default.
+func cacheDir() (string, error) {
+	dir := os.Getenv("GH_USER_STATUS_CACHE_DIR")
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("could not find cache directory: %w", err)
+		}
+		dir = filepath.Join(base, "gh-user-status")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create cache directory: %w", err)
+	}
+	return dir, nil
+}
+
+// ghHost returns the GitHub host gh talks to, so that cached statuses from
+// different hosts don't mix.
+func ghHost() string {
+	if host := os.Getenv("GH_HOST"); host != "" {
+		return host
+	}
+	return "github.com"
+}
+
+// cachedStatus is login's status as it was when FetchedAt.
+type cachedStatus struct {
+	FetchedAt time.Time
+	Login     string
+	Status    *status.Status
+}
+
+var unsafeCacheKeyRE = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// cacheKey turns name into part of a file name, prefixed with the current
+// host.
+func cacheKey(name string) string {
+	return unsafeCacheKeyRE.ReplaceAllString(strings.ToLower(ghHost()+"_"+name), "_")
+}
+
+// statusCachePath returns the cache file for login on the current host. An
+// empty login stands for the viewer.
+func statusCachePath(login string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	if login == "" {
+		login = "@viewer"
+	}
+	return filepath.Join(dir, fmt.Sprintf("status-%s.json", cacheKey(login))), nil
+}
+
+// viewerLoginPath returns the file remembering the viewer's login on the
+// current host. Unlike the viewer's cached status, it outlives invalidation.
+func viewerLoginPath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("viewer-%s.json", cacheKey("login"))), nil
+}
+
+// readStatusCache returns the cached status for login, or nil if there is
+// none.
+func readStatusCache(login string) (*cachedStatus, error) {
+	path, err := statusCachePath(login)
+	if err != nil {
+		return nil, err
+	}
+	var c *cachedStatus
+	if err := readJSONFile(path, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// writeStatusCache records s as the status of resolvedLogin, and also of the
+// viewer when login is empty.
+func writeStatusCache(login, resolvedLogin string, s *status.Status) error {
+	c := cachedStatus{FetchedAt: time.Now(), Login: resolvedLogin, Status: s}
+	if login == "" && resolvedLogin != "" {
+		path, err := viewerLoginPath()
+		if err != nil {
+			return err
+		}
+		if err := writeJSONFile(path, resolvedLogin); err != nil {
+			return err
+		}
+	}
+	logins := []string{}
+	if resolvedLogin != "" {
+		logins = append(logins, resolvedLogin)
+	}
+	if login == "" {
+		logins = append(logins, "")
+	}
+	for _, l := range logins {
+		path, err := statusCachePath(l)
+		if err != nil {
+			return err
+		}
+		if err := writeJSONFile(path, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidateViewerCache forgets the viewer's cached status, both as the
+// viewer and under their login, after it has been changed. When the viewer's
+// login was never learned, every cached status for the host is forgotten
+// instead, since any of them could be the viewer's.
+func invalidateViewerCache() {
+	if path, err := statusCachePath(""); err == nil {
+		_ = os.Remove(path)
+	}
+
+	var login string
+	if path, err := viewerLoginPath(); err == nil {
+		_ = readJSONFile(path, &login)
+	}
+	if login != "" {
+		if path, err := statusCachePath(login); err == nil {
+			_ = os.Remove(path)
+		}
+		return
+	}
+
+	dir, err := cacheDir()
+	if err != nil {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, fmt.Sprintf("status-%s*.json", cacheKey(""))))
+	for _, path := range paths {
+		_ = os.Remove(path)
+	}
+}
+
+// cachedAPIStatus returns login's cached status if it is younger than ttl,
+// and otherwise fetches it with apiStatus. A ttl of zero skips the cache.
+// Entries are only ever refreshed in full: GraphQL requests are POSTs, which
+// GitHub doesn't answer conditionally.
+func cachedAPIStatus(login string, ttl time.Duration) (*status.Status, error) {
+	if ttl > 0 {
+		if c, _ := readStatusCache(login); c != nil && time.Since(c.FetchedAt) < ttl {
+			return c.Status, nil
+		}
+	}
+	return apiStatus(login)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// calendarRule maps events whose title contains one of Keywords to a status
+// emoji and availability.
+type calendarRule struct {
+	Keywords []string
+	Emoji    string
+	Limited  bool
+}
+
+type calendarRules struct {
+	Rules []calendarRule
+	// Default applies to timed events that match no rule. All-day events
+	// that match no rule, like birthdays, are ignored.
+	Default calendarRule
+}
+
+// Keywords for time off, shared by the calendar rules and report.
+var (
+	timeOffKeywords = []string{"ooo", "out of office", "vacation", "holiday", "pto", "on leave", "parental leave"}
+	sickKeywords    = []string{"sick"}
+)
+
+var defaultCalendarRules = calendarRules{
+	Rules: []calendarRule{
+		{Keywords: timeOffKeywords, Emoji: "palm_tree", Limited: true},
+		{Keywords: sickKeywords, Emoji: "face_with_thermometer", Limited: true},
+		{Keywords: []string{"focus"}, Emoji: "no_entry", Limited: true},
+	},
+	Default: calendarRule{Emoji: "date"},
+}
+
+// match returns the rule for the event titled summary and its position in
+// the rules file, or false if no rule applies.
+func (cr calendarRules) match(summary string, allDay bool) (calendarRule, int, bool) {
+	lower := strings.ToLower(summary)
+	for i, rule := range cr.Rules {
+		for _, kw := range rule.Keywords {
+			if strings.Contains(lower, strings.ToLower(kw)) {
+				return rule, i, true
+			}
+		}
+	}
+	if allDay {
+		return calendarRule{}, 0, false
+	}
+	return cr.Default, len(cr.Rules), true
+}
+
+// calendarState remembers the status sync-calendar last set, so that it only
+// ever clears a status it set itself.
+type calendarState struct {
+	Message string
+	Emoji   string
+}
+
+type syncCalendarOptions struct {
+	Path      string
+	RulesPath string
+}
+
+func syncCalendarCmd() *cobra.Command {
+	opts := syncCalendarOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync-calendar <file.ics>",
+		Short: "set your status from the calendar event in progress",
+		Long: `Set your status from whichever event in an iCalendar file is in progress now.
+
+The status message is the event's title and it expires when the event ends.
+When no event is in progress, a status previously set by sync-calendar is
+cleared; a status you set some other way is left alone.
+
+The emoji and availability for an event are chosen by the first rule whose
+keywords appear in its title. Rules are read from calendar-rules.json in the
+extension's config directory, or from --rules:
+
+  {
+    "rules": [
+      {"keywords": ["ooo", "vacation"], "emoji": "palm_tree", "limited": true}
+    ],
+    "default": {"emoji": "date", "limited": false}
+  }
+
+All-day events are only used when they match a rule.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Path = args[0]
+			return runSyncCalendar(opts, time.Now())
+		},
+	}
+	cmd.Flags().StringVarP(&opts.RulesPath, "rules", "r", "", "Path to a rules file")
+
+	return cmd
+}
+
+func loadCalendarRules(path string) (calendarRules, error) {
+	if path == "" {
+		var err error
+		path, err = configPath("calendar-rules.json")
+		if err != nil {
+			return calendarRules{}, err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return defaultCalendarRules, nil
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return calendarRules{}, err
+	}
+
+	rules := calendarRules{}
+	if err := readJSONFile(path, &rules); err != nil {
+		return rules, err
+	}
+	if rules.Default.Emoji == "" {
+		rules.Default.Emoji = defaultCalendarRules.Default.Emoji
+	}
+	return rules, nil
+}
+
+func runSyncCalendar(opts syncCalendarOptions, now time.Time) error {
+	rules, err := loadCalendarRules(opts.RulesPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(opts.Path)
+	if err != nil {
+		return err
+	}
+	events, err := parseICalendar(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", opts.Path, err)
+	}
+
+	// Prefer events matched by earlier rules, then whichever started last.
+	var best *calendarOccurrence
+	var bestRule calendarRule
+	bestRank := 0
+	for _, occ := range occurrencesAt(events, now) {
+		occ := occ
+		rule, rank, ok := rules.match(occ.Event.Summary, occ.Event.AllDay)
+		if !ok {
+			continue
+		}
+		if best == nil || rank < bestRank || (rank == bestRank && !occ.Start.Before(best.Start)) {
+			best, bestRule, bestRank = &occ, rule, rank
+		}
+	}
+
+	statePath, err := configPath("calendar-state.json")
+	if err != nil {
+		return err
+	}
+	state := calendarState{}
+	if err := readJSONFile(statePath, &state); err != nil {
+		return err
+	}
+
+	current, err := apiStatus("")
+	if err != nil {
+		return err
+	}
+
+	if best == nil {
+		if state.Message == "" || current.Message != state.Message || current.Emoji != state.Emoji {
+			return nil
+		}
+		if err := runClear(); err != nil {
+			return err
+		}
+		return writeJSONFile(statePath, calendarState{})
+	}
+
+	// Event titles aren't ours to shorten, and under cron nobody is there to
+	// be asked, so cut long ones down to what GitHub accepts.
+	setOpts := setOptions{
+		Message: truncateMessage(status.NewEmojiManager(), best.Event.Summary),
+		Emoji:   bestRule.Emoji,
+		Limited: bestRule.Limited,
+		Expiry:  best.End.Sub(now),
+	}
+	emoji := fmt.Sprintf(":%s:", setOpts.Emoji)
+	if current.Message == setOpts.Message && current.Emoji == emoji && current.IndicatesLimitedAvailability == setOpts.Limited {
+		return nil
+	}
+	if err := runSet(setOpts); err != nil {
+		return err
+	}
+
+	return writeJSONFile(statePath, calendarState{Message: setOpts.Message, Emoji: emoji})
+}
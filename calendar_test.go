@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncCalendarTruncatesLongTitle(t *testing.T) {
+	setupTest(t, "get_viewer", "set_calendar_truncated")
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	ics := calendar("BEGIN:VEVENT\r\nUID:1\r\nSUMMARY:Quarterly planning review " + strings.Repeat("x", 60) +
+		"\r\nDTSTART:20210607T100000\r\nDTEND:20210607T120000\r\nEND:VEVENT\r\n")
+	if err := ioutil.WriteFile(path, []byte(ics), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runSyncCalendar(syncCalendarOptions{Path: path}, local(2021, 6, 7, 11, 0)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
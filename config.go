@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// configDir returns the directory the extension keeps its local files in,
+// creating it if needed. GH_USER_STATUS_CONFIG_DIR overrides the default.
+func configDir() (string, error) {
+	dir := os.Getenv("GH_USER_STATUS_CONFIG_DIR")
+	if dir == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("could not find config directory: %w", err)
+		}
+		dir = filepath.Join(base, "gh-user-status")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create config directory: %w", err)
+	}
+	return dir, nil
+}
+
+// config is the extension's general configuration, read from config.json
+// in configDir.
+type config struct {
+	Webhooks []webhookTarget
+	// Vars are preset variables for status messages, as {{vars.name}}.
+	Vars map[string]string
+}
+
+func loadConfig() (*config, error) {
+	path, err := configPath("config.json")
+	if err != nil {
+		return nil, err
+	}
+	c := &config{}
+	if err := readJSONFile(path, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// configPath returns the path of name inside configDir.
+func configPath(name string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// readJSONFile decodes the JSON file at path into v. A missing file is not an
+// error and leaves v untouched.
+func readJSONFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile atomically replaces the file at path with v encoded as JSON.
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
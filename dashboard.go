@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+	"golang.org/x/term"
+)
+
+type dashboardOptions struct {
+	Logins   []string
+	Team     string
+	Interval time.Duration
+}
+
+func dashboardCmd() *cobra.Command {
+	opts := dashboardOptions{}
+	cmd := &cobra.Command{
+		Use:   "dashboard [<username>...]",
+		Short: "show a live view of a team's statuses",
+		Long: `Show the statuses of the given users, or of every member of a team, in a
+full-screen view that refreshes in the background.
+
+Keys:
+
+  /      filter by login, message or emoji; Esc clears the filter
+  l      only show members with limited availability
+  s      set your status, written like ":coffee: on a break !limited @30m"
+  c      clear your status
+  r      refresh now
+  j, k   scroll
+  q      quit`,
+		Example: `  gh user-status dashboard --team cli/maintainers`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Logins = args
+			if err := checkTargets(opts.Logins, opts.Team); err != nil {
+				return err
+			}
+			if err := checkPollInterval(opts.Interval); err != nil {
+				return err
+			}
+			return runDashboard(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Team, "team", "t", "", "Show every member of an <org>/<team>")
+	cmd.Flags().DurationVarP(&opts.Interval, "interval", "i", time.Minute, "How often to refresh")
+
+	return cmd
+}
+
+type dashboardMode int
+
+const (
+	modeBrowse dashboardMode = iota
+	modeFilter
+	modeSet
+)
+
+type dashboardAction int
+
+const (
+	actionNone dashboardAction = iota
+	actionQuit
+	actionRefresh
+	actionSet
+	actionClear
+)
+
+// dashboard is the state of the dashboard's screen, kept apart from the
+// terminal so that it can be driven by key presses in tests.
+type dashboard struct {
+	em          status.EmojiManager
+	title       string
+	statuses    []status.MemberStatus
+	fetchedAt   time.Time
+	filter      string
+	limitedOnly bool
+	mode        dashboardMode
+	input       []rune
+	offset      int
+	notice      string
+}
+
+// visible returns the statuses that pass the filters, out and limited members
+// first.
+func (d *dashboard) visible() []status.MemberStatus {
+	filter := strings.ToLower(d.filter)
+	out := []status.MemberStatus{}
+	for _, ms := range d.statuses {
+		s := ms.Status
+		if s == nil {
+			s = &status.Status{}
+		}
+		if d.limitedOnly && !s.IndicatesLimitedAvailability {
+			continue
+		}
+		if filter != "" &&
+			!strings.Contains(strings.ToLower(ms.Login), filter) &&
+			!strings.Contains(strings.ToLower(s.Message), filter) &&
+			!strings.Contains(strings.ToLower(s.Emoji), filter) {
+			continue
+		}
+		out = append(out, ms)
+	}
+
+	rank := map[string]int{}
+	for i, g := range reportGroups {
+		rank[g] = i
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		gi, gj := availabilityGroup(out[i].Status), availabilityGroup(out[j].Status)
+		if gi != gj {
+			return rank[gi] < rank[gj]
+		}
+		return strings.ToLower(out[i].Login) < strings.ToLower(out[j].Login)
+	})
+	return out
+}
+
+// handleKey applies a chunk of input read from the terminal, returning what
+// the caller should do about it. For actionSet, the argument is the status
+// line that was typed.
+func (d *dashboard) handleKey(key string) (dashboardAction, string) {
+	if key == "\x03" {
+		return actionQuit, ""
+	}
+
+	if d.mode != modeBrowse {
+		switch {
+		case key == "\x1b":
+			if d.mode == modeFilter {
+				d.filter = ""
+			}
+			d.mode = modeBrowse
+		case key == "\r" || key == "\n":
+			line := string(d.input)
+			mode := d.mode
+			d.mode = modeBrowse
+			if mode == modeSet {
+				return actionSet, line
+			}
+		case key == "\x7f" || key == "\b":
+			if len(d.input) > 0 {
+				d.input = d.input[:len(d.input)-1]
+			}
+		case !strings.HasPrefix(key, "\x1b"):
+			for _, r := range key {
+				if r >= ' ' {
+					d.input = append(d.input, r)
+				}
+			}
+		}
+		if d.mode == modeFilter {
+			d.filter = string(d.input)
+			d.offset = 0
+		}
+		return actionNone, ""
+	}
+
+	switch key {
+	case "q":
+		return actionQuit, ""
+	case "/":
+		d.mode = modeFilter
+		d.input = []rune(d.filter)
+	case "\x1b":
+		d.filter = ""
+	case "l":
+		d.limitedOnly = !d.limitedOnly
+		d.offset = 0
+	case "s":
+		d.mode = modeSet
+		d.input = nil
+	case "c":
+		return actionClear, ""
+	case "r":
+		return actionRefresh, ""
+	case "j", "\x1b[B":
+		d.offset++
+	case "k", "\x1b[A":
+		if d.offset > 0 {
+			d.offset--
+		}
+	}
+	return actionNone, ""
+}
+
+// formatRemaining describes how long is left until a status expires.
+func formatRemaining(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+// render draws the whole screen. Lines end in \r\n since the terminal is in
+// raw mode.
+func (d *dashboard) render(w io.Writer, width, height int, now time.Time) {
+	rows := d.visible()
+	limited := 0
+	for _, ms := range d.statuses {
+		if ms.Status != nil && ms.Status.IndicatesLimitedAvailability {
+			limited++
+		}
+	}
+
+	lines := []string{}
+	header := fmt.Sprintf("\x1b[1m%s\x1b[0m  %d members, %d limited", d.title, len(d.statuses), limited)
+	if d.fetchedAt.IsZero() {
+		header += " · loading…"
+	} else {
+		header += " · updated " + d.fetchedAt.Format("15:04:05")
+	}
+	if d.limitedOnly {
+		header += " · limited only"
+	}
+	if d.filter != "" {
+		header += fmt.Sprintf(" · filter %q", d.filter)
+	}
+	lines = append(lines, header)
+
+	loginWidth := 5
+	for _, ms := range rows {
+		if n := len(ms.Login); n > loginWidth {
+			loginWidth = n
+		}
+	}
+	if loginWidth > 20 {
+		loginWidth = 20
+	}
+	messageWidth := width - (3 + loginWidth + 1 + 7 + 1 + 8 + 1)
+	if messageWidth < 10 {
+		messageWidth = 10
+	}
+	lines = append(lines, fmt.Sprintf("\x1b[2m   %-*s %-7s %-8s %s\x1b[0m", loginWidth, "LOGIN", "LIMITED", "EXPIRES", "STATUS"))
+
+	space := height - 4
+	if space < 1 {
+		space = 1
+	}
+	if last := len(rows) - space; d.offset > last {
+		d.offset = last
+	}
+	if d.offset < 0 {
+		d.offset = 0
+	}
+	end := d.offset + space
+	if end > len(rows) {
+		end = len(rows)
+	}
+	for _, ms := range rows[d.offset:end] {
+		s := ms.Status
+		if s == nil || (s.Message == "" && s.Emoji == "") {
+			lines = append(lines, fmt.Sprintf("\x1b[2m   %-*s %-7s %-8s %s\x1b[0m",
+				loginWidth, truncate(ms.Login, loginWidth), "", "", "no status"))
+			continue
+		}
+		emoji := "  "
+		if s.Emoji != "" {
+			emoji = d.em.ReplaceAll(s.Emoji)
+		}
+		limitedFlag, expires := "", ""
+		if s.IndicatesLimitedAvailability {
+			limitedFlag = "limited"
+		}
+		if s.ExpiresAt != nil {
+			expires = formatRemaining(s.ExpiresAt.Sub(now))
+		}
+		lines = append(lines, fmt.Sprintf("%s %-*s %-7s %-8s %s",
+			emoji, loginWidth, truncate(ms.Login, loginWidth), limitedFlag, expires, truncate(s.Message, messageWidth)))
+	}
+	if len(rows) == 0 && !d.fetchedAt.IsZero() {
+		lines = append(lines, "\x1b[2m   nobody matches\x1b[0m")
+	}
+
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, truncate(d.notice, width))
+	switch d.mode {
+	case modeFilter:
+		lines = append(lines, "/"+string(d.input)+"█")
+	case modeSet:
+		lines = append(lines, "Set status: "+string(d.input)+"█")
+	default:
+		lines = append(lines, "\x1b[2m/ filter  l limited only  s set  c clear  r refresh  q quit\x1b[0m")
+	}
+
+	fmt.Fprint(w, "\x1b[H\x1b[2J"+strings.Join(lines, "\r\n"))
+}
+
+// dashboardUpdate is the result of one fetch of the statuses.
+type dashboardUpdate struct {
+	statuses []status.MemberStatus
+	err      error
+}
+
+func runDashboard(opts dashboardOptions) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("the dashboard needs a terminal; try `gh user-status watch` instead")
+	}
+
+	d := &dashboard{em: status.NewEmojiManager(), title: opts.Team}
+	if d.title == "" {
+		d.title = strings.Join(opts.Logins, ", ")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan dashboardUpdate)
+	send := func(u dashboardUpdate) {
+		select {
+		case updates <- u:
+		case <-ctx.Done():
+		}
+	}
+	fetch := func() ([]status.MemberStatus, *rateLimit, error) {
+		return fetchStatuses(opts.Logins, opts.Team)
+	}
+	refresh := func() {
+		statuses, _, err := fetch()
+		send(dashboardUpdate{statuses, err})
+	}
+	go poller{Interval: opts.Interval, Fetch: fetch}.Run(ctx, func(statuses []status.MemberStatus) {
+		send(dashboardUpdate{statuses: statuses})
+	}, func(err error, retryIn time.Duration) {
+		send(dashboardUpdate{err: fmt.Errorf("%w; retrying in %s", err, retryIn)})
+	})
+
+	// Input is only read when asked for, so that nothing competes with the
+	// prompts shown while setting a status.
+	keys := make(chan string)
+	readMore := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 64)
+		for range readMore {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	}()
+	defer close(readMore)
+	readMore <- struct{}{}
+
+	fd := int(os.Stdin.Fd())
+	var saved *term.State
+	enter := func() error {
+		var err error
+		saved, err = term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("could not set up the terminal: %w", err)
+		}
+		fmt.Print("\x1b[?1049h\x1b[?25l")
+		return nil
+	}
+	leave := func() {
+		fmt.Print("\x1b[H\x1b[2J\x1b[?25h")
+		_ = term.Restore(fd, saved)
+	}
+	if err := enter(); err != nil {
+		return err
+	}
+	defer fmt.Print("\x1b[?1049l")
+	defer leave()
+
+	draw := func() {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		d.render(os.Stdout, width, height, time.Now())
+	}
+	// changeOwnStatus runs fn with the terminal back in its normal mode, so
+	// that it can prompt for the user scope if it needs to.
+	changeOwnStatus := func(fn func() error, done string) error {
+		leave()
+		err := fn()
+		if enterErr := enter(); enterErr != nil {
+			return enterErr
+		}
+		if err != nil {
+			d.notice = "! " + err.Error()
+		} else {
+			d.notice = done
+			go refresh()
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		draw()
+		select {
+		case u := <-updates:
+			if u.err != nil {
+				d.notice = "! " + u.err.Error()
+				continue
+			}
+			d.statuses = u.statuses
+			d.fetchedAt = time.Now()
+			if strings.HasPrefix(d.notice, "! ") {
+				d.notice = ""
+			}
+		case <-ticker.C:
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			action, arg := d.handleKey(key)
+			var err error
+			switch action {
+			case actionQuit:
+				return nil
+			case actionRefresh:
+				d.notice = "Refreshing…"
+				go refresh()
+			case actionClear:
+				err = changeOwnStatus(runClear, "✓ Status cleared")
+			case actionSet:
+				fs, parseErr := parseStatusLine(d.em, arg)
+				switch {
+				case parseErr != nil:
+					d.notice = "! " + parseErr.Error()
+				case fs.Message == "":
+					d.notice = "! A status needs a message; press c to clear yours"
+				default:
+					err = changeOwnStatus(func() error {
+						return runSet(setOptions{
+							Message: fs.Message,
+							Emoji:   fs.Emoji,
+							Limited: fs.Limited,
+							Expiry:  fs.Expiry,
+						})
+					}, d.em.ReplaceAll(fmt.Sprintf("✓ Status set to :%s: %s", fs.Emoji, fs.Message)))
+				}
+			}
+			if err != nil {
+				return err
+			}
+			readMore <- struct{}{}
+		}
+	}
+}
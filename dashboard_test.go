@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func testDashboard() *dashboard {
+	later := time.Date(2021, 6, 1, 14, 30, 0, 0, time.UTC)
+	return &dashboard{
+		em:    status.NewEmojiManager(),
+		title: "cli/maintainers",
+		statuses: []status.MemberStatus{
+			{Login: "vilmibm", Status: &status.Status{Message: "lunch", Emoji: ":pizza:", ExpiresAt: &later}},
+			{Login: "mislav", Status: &status.Status{Message: "heads down", Emoji: ":thought_balloon:", IndicatesLimitedAvailability: true}},
+			{Login: "samcoe", Status: &status.Status{Message: "on vacation", Emoji: ":palm_tree:"}},
+			{Login: "monalisa"},
+		},
+		fetchedAt: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func logins(statuses []status.MemberStatus) string {
+	names := []string{}
+	for _, ms := range statuses {
+		names = append(names, ms.Login)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestDashboardVisible(t *testing.T) {
+	d := testDashboard()
+	if got, want := logins(d.visible()), "samcoe mislav monalisa vilmibm"; got != want {
+		t.Errorf("got %q, want out and limited members first, %q", got, want)
+	}
+
+	d.limitedOnly = true
+	if got := logins(d.visible()); got != "mislav" {
+		t.Errorf("expected only limited members, got %q", got)
+	}
+
+	d.limitedOnly = false
+	d.filter = "PIZZA"
+	if got := logins(d.visible()); got != "vilmibm" {
+		t.Errorf("expected the filter to match emoji, got %q", got)
+	}
+}
+
+func TestDashboardKeys(t *testing.T) {
+	d := testDashboard()
+
+	for _, k := range []string{"/", "l", "u", "n"} {
+		d.handleKey(k)
+	}
+	if d.filter != "lun" || d.limitedOnly {
+		t.Errorf("expected keys to be typed into the filter, got %q, %t", d.filter, d.limitedOnly)
+	}
+	d.handleKey("\x7f")
+	d.handleKey("\r")
+	if d.filter != "lu" || d.mode != modeBrowse {
+		t.Errorf("expected the filter to be kept after Enter, got %q", d.filter)
+	}
+	d.handleKey("\x1b")
+	if d.filter != "" {
+		t.Errorf("expected Esc to clear the filter, got %q", d.filter)
+	}
+
+	d.handleKey("s")
+	for _, k := range []string{":coffee: ", "break"} {
+		if action, _ := d.handleKey(k); action != actionNone {
+			t.Errorf("unexpected action %d while typing", action)
+		}
+	}
+	action, line := d.handleKey("\r")
+	if action != actionSet || line != ":coffee: break" {
+		t.Errorf("got %d %q, want a set of the typed line", action, line)
+	}
+
+	if action, _ := d.handleKey("c"); action != actionClear {
+		t.Errorf("expected c to clear, got %d", action)
+	}
+	if action, _ := d.handleKey("q"); action != actionQuit {
+		t.Errorf("expected q to quit, got %d", action)
+	}
+}
+
+func TestDashboardRender(t *testing.T) {
+	d := testDashboard()
+	var buf bytes.Buffer
+	d.render(&buf, 80, 24, time.Date(2021, 6, 1, 12, 15, 0, 0, time.UTC))
+	out := buf.String()
+
+	for _, want := range []string{
+		"4 members, 1 limited · updated 12:00:00",
+		"🍕 vilmibm          2h15m    lunch",
+		"💭 mislav   limited          heads down",
+		"no status",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
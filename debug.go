@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// debugOutput is where API traffic is logged, or nil when it isn't.
+var debugOutput io.Writer
+
+// setupDebug turns on logging of API traffic for --verbose or
+// GH_USER_STATUS_DEBUG. The variable may be 1 or true for STDERR, or the path
+// of a file to append to.
+func setupDebug(verbose bool) error {
+	if f, ok := debugOutput.(*os.File); ok && f != os.Stderr {
+		f.Close()
+	}
+	debugOutput = nil
+	switch v := os.Getenv("GH_USER_STATUS_DEBUG"); strings.ToLower(v) {
+	case "", "0", "false":
+		if verbose {
+			debugOutput = os.Stderr
+		}
+	case "1", "true":
+		debugOutput = os.Stderr
+	default:
+		f, err := os.OpenFile(v, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("could not open debug log: %w", err)
+		}
+		debugOutput = f
+	}
+
+	if _, ok := apiClient.Exec.(debugExecutor); debugOutput != nil && !ok {
+		apiClient.Exec = debugExecutor{apiClient.Exec}
+	}
+	return nil
+}
+
+func debugf(format string, args ...interface{}) {
+	if debugOutput == nil {
+		return
+	}
+	fmt.Fprintf(debugOutput, "[%s] %s\n", time.Now().Format("15:04:05.000"), fmt.Sprintf(format, args...))
+}
+
+// secretKeyRE matches the names of JSON fields and headers whose values are
+// never logged.
+var secretKeyRE = regexp.MustCompile(`(?i)token|secret|password|authorization|cookie`)
+
+// redact returns a JSON request body for logging, with secret values masked
+// and whitespace collapsed. Anything else is returned as is. Responses are
+// logged raw, since re-encoding them would hide what was actually received.
+func redact(b []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return strings.TrimSpace(string(b))
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return strings.TrimSpace(string(b))
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if secretKeyRE.MatchString(k) {
+				v[k] = "REDACTED"
+			} else if s, ok := e.(string); ok && k == "query" {
+				v[k] = strings.Join(strings.Fields(s), " ")
+			} else {
+				v[k] = redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactValue(e)
+		}
+	}
+	return v
+}
+
+// debugExecutor logs each gh invocation with the request it was sent, how
+// long it took and what it printed. Terminals are passed through untouched so
+// that interactive commands such as `gh auth refresh` keep working.
+type debugExecutor struct {
+	exec status.Executor
+}
+
+func (e debugExecutor) Run(stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	debugf("gh %s", strings.Join(args, " "))
+	if _, ok := stdin.(*os.File); stdin != nil && !ok {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		debugf("request: %s", redact(b))
+		stdin = bytes.NewReader(b)
+	}
+	var sout, eout bytes.Buffer
+	if _, ok := stdout.(*os.File); !ok {
+		stdout = io.MultiWriter(stdout, &sout)
+	}
+	if _, ok := stderr.(*os.File); !ok {
+		stderr = io.MultiWriter(stderr, &eout)
+	}
+
+	start := time.Now()
+	err := e.exec.Run(stdin, stdout, stderr, args...)
+	if err != nil {
+		debugf("gh failed after %s: %s", time.Since(start).Round(time.Millisecond), err)
+	} else {
+		debugf("gh finished after %s", time.Since(start).Round(time.Millisecond))
+	}
+	if eout.Len() > 0 {
+		debugf("stderr: %s", strings.TrimSpace(eout.String()))
+	}
+	if sout.Len() > 0 {
+		debugf("response: %s", strings.TrimSpace(sout.String()))
+	}
+	return err
+}
+
+// newHTTPClient returns a client for webhooks and Slack that logs its
+// requests when debugging is on.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	c := &http.Client{Timeout: timeout}
+	if debugOutput != nil {
+		c.Transport = debugTransport{http.DefaultTransport}
+	}
+	return c
+}
+
+// redactURL returns u with its path and query masked.
+func redactURL(u *url.URL) string {
+	s := u.Scheme + "://" + u.Host
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		s += "/REDACTED"
+	}
+	return s
+}
+
+// debugTransport logs each HTTP request and response. Only the scheme and host
+// of URLs are logged, since webhook URLs often carry a secret in their path or
+// query.
+type debugTransport struct {
+	rt http.RoundTripper
+}
+
+func (t debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	debugf("%s %s", req.Method, redactURL(req.URL))
+	names := []string{}
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(req.Header[name], ", ")
+		if secretKeyRE.MatchString(name) {
+			value = "REDACTED"
+		}
+		debugf("%s: %s", name, value)
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		debugf("request: %s", redact(b))
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		debugf("request failed after %s: %s", time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	debugf("%s after %s", resp.Status, time.Since(start).Round(time.Millisecond))
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	debugf("response: %s", strings.TrimSpace(string(b)))
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	in := `{"query": "query {\n\tviewer { login }\n}", "variables": {"token": "xoxp-1", "message": "lunch"}, "secret": "s3cret"}`
+	want := `{"query":"query { viewer { login } }","secret":"REDACTED","variables":{"message":"lunch","token":"REDACTED"}}`
+	if got := redact([]byte(in)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := redact([]byte("not json\n")); got != "not json" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDebugLogsGH(t *testing.T) {
+	setupTest(t, "set_pizza")
+	path := filepath.Join(t.TempDir(), "debug.log")
+	setenv(t, "GH_USER_STATUS_DEBUG", path)
+	t.Cleanup(func() { debugOutput = nil })
+
+	out, err := runCommand(t, "set", "-e", "pizza", "lunch")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🍕 lunch\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"gh api graphql --input -",
+		`"message":"lunch"`,
+		"gh finished after",
+		"response: {\n",
+		// Logged as received rather than re-encoded.
+		`"message": "lunch",`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in the log:\n%s", want, b)
+		}
+	}
+}
+
+func TestDebugTransport(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != `{"profile":{}}` {
+			t.Errorf("request body was lost, got %q", b)
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer s.Close()
+
+	var log bytes.Buffer
+	debugOutput = &log
+	t.Cleanup(func() { debugOutput = nil })
+
+	req, err := http.NewRequest("POST", s.URL+"/hooks/T0/B0/s3cret?token=xyz", strings.NewReader(`{"profile":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer xoxp-secret")
+	resp, err := newHTTPClient(0).Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != `{"ok": true}` {
+		t.Errorf("response body was lost, got %q", b)
+	}
+
+	got := log.String()
+	for _, want := range []string{"POST " + s.URL + "/REDACTED\n", "Authorization: REDACTED", "200 OK after", `response: {"ok": true}`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the log:\n%s", want, got)
+		}
+	}
+	for _, secret := range []string{"xoxp-secret", "s3cret", "xyz"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("%q was logged:\n%s", secret, got)
+		}
+	}
+}
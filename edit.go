@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func editCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "edit your status in your editor",
+		Long: `Open your current status in your editor, as the message preceded by front
+matter for its emoji, limited availability, expiry and organization. When the
+editor exits, whatever was changed is applied. Emptying the message clears
+your status, and emptying the whole file leaves it alone.
+
+The editor is $GH_EDITOR, $VISUAL or $EDITOR. If the file can't be understood
+it is opened again with the problem noted at the top.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEdit()
+		},
+	}
+}
+
+// editedStatus is a status as written in the file opened by edit. Expiry and
+// Org are kept as written so that untouched fields are recognised.
+type editedStatus struct {
+	Message string
+	Emoji   string
+	Limited bool
+	Expiry  string
+	Org     string
+}
+
+const editHelp = `# Edit your status above. Lines starting with # below it are ignored.
+#
+# emoji:   a shortcode such as pizza or palm_tree
+# limited: true or false
+# expiry:  never, a duration like 2h or 3d, or a time like "2006-01-02 15:04"
+#          or "fri 17:00"
+#
+# An empty message clears your status; an empty file changes nothing.
+`
+
+func newEditedStatus(s *status.Status) editedStatus {
+	e := editedStatus{
+		Message: s.Message,
+		Emoji:   strings.Trim(s.Emoji, ":"),
+		Limited: s.IndicatesLimitedAvailability,
+		Expiry:  "never",
+	}
+	if e.Emoji == "" {
+		e.Emoji = "thought_balloon"
+	}
+	if s.ExpiresAt != nil {
+		e.Expiry = s.ExpiresAt.Local().Format("2006-01-02 15:04")
+	}
+	if s.Organization != nil {
+		e.Org = s.Organization.Login
+	}
+	return e
+}
+
+// formatStatusDocument writes e as front matter followed by the message.
+func formatStatusDocument(e editedStatus) string {
+	return fmt.Sprintf("---\nemoji: %s\nlimited: %t\nexpiry: %s\norg: %s\n---\n%s\n\n%s",
+		e.Emoji, e.Limited, e.Expiry, e.Org, e.Message, editHelp)
+}
+
+var errEmptyDocument = errors.New("the file was empty")
+
+// parseStatusDocument reads a file written by formatStatusDocument back. Lines
+// starting with # are comments before and in the front matter, but after it
+// only once the message has ended, so that a message may start with #.
+func parseStatusDocument(em status.EmojiManager, doc string) (editedStatus, error) {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	for len(lines) > 0 && (strings.TrimSpace(lines[0]) == "" || strings.HasPrefix(lines[0], "#")) {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return editedStatus{}, errEmptyDocument
+	}
+	if strings.TrimSpace(lines[0]) != "---" {
+		return editedStatus{}, errors.New("the file must start with --- and the front matter")
+	}
+
+	e := editedStatus{}
+	seen := map[string]bool{}
+	i := 1
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "---" {
+			break
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := cut(line, ":")
+		if !ok {
+			return e, fmt.Errorf("expected key: value in the front matter, got %q", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if seen[key] {
+			return e, fmt.Errorf("%s is given twice", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "emoji":
+			if _, ok := em.Lookup(value); !ok {
+				return e, fmt.Errorf("unknown emoji %q", value)
+			}
+			e.Emoji = strings.Trim(value, ":")
+		case "limited":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return e, fmt.Errorf("limited must be true or false, not %q", value)
+			}
+			e.Limited = b
+		case "expiry":
+			e.Expiry = value
+		case "org":
+			e.Org = value
+		default:
+			return e, fmt.Errorf("unknown front matter field %q", key)
+		}
+	}
+	if i == len(lines) {
+		return e, errors.New("the front matter must end with ---")
+	}
+	for _, key := range []string{"emoji", "limited", "expiry"} {
+		if !seen[key] {
+			return e, fmt.Errorf("%s is missing from the front matter", key)
+		}
+	}
+
+	e.Message = editedMessage(lines[i+1:])
+	if e.Message != "" {
+		if err := validateMessage(em, e.Message); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
+
+// editedMessage joins the lines after the front matter into the message.
+// Lines of the help text are ignored wherever they are, and other lines
+// starting with # once a blank line has ended the message.
+func editedMessage(lines []string) string {
+	help := map[string]bool{}
+	for _, line := range strings.Split(editHelp, "\n") {
+		if line != "" {
+			help[line] = true
+		}
+	}
+
+	words := []string{}
+	ended := false
+	for _, line := range lines {
+		switch {
+		case help[line]:
+		case strings.TrimSpace(line) == "":
+			ended = len(words) > 0
+		case ended && strings.HasPrefix(line, "#"):
+		default:
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// cut slices s around the first sep, like strings.Cut.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// parseExpiry turns an edited expiry into how long the status should last,
+// where zero means it never expires.
+func parseExpiry(s string, now time.Time) (time.Duration, error) {
+	if s == "" || strings.EqualFold(s, "never") {
+		return 0, nil
+	}
+	if d, err := status.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	t, err := parseWhen(s, now)
+	if err != nil {
+		return 0, fmt.Errorf("could not understand expiry %q", s)
+	}
+	if !t.After(now) {
+		return 0, fmt.Errorf("expiry %q is in the past", s)
+	}
+	return t.Sub(now), nil
+}
+
+// withEditError puts err at the top of doc, replacing any earlier error.
+func withEditError(doc string, err error) string {
+	lines := strings.Split(doc, "\n")
+	for len(lines) > 0 && strings.HasPrefix(lines[0], "# ERROR: ") {
+		lines = lines[1:]
+	}
+	return fmt.Sprintf("# ERROR: %s\n%s", err, strings.Join(lines, "\n"))
+}
+
+func runEdit() error {
+	em := status.NewEmojiManager()
+	current, err := apiStatus("")
+	if err != nil {
+		return err
+	}
+	before := newEditedStatus(current)
+
+	var after editedStatus
+	var expiry time.Duration
+	doc := formatStatusDocument(before)
+	for {
+		doc, err = openEditor("STATUS.md", doc)
+		if err != nil {
+			return err
+		}
+		after, err = parseStatusDocument(em, doc)
+		if err == nil {
+			expiry, err = editedExpiry(current, before, after)
+		}
+		if errors.Is(err, errEmptyDocument) {
+			fmt.Println("Empty file; leaving your status alone")
+			return nil
+		}
+		if err == nil {
+			break
+		}
+		doc = withEditError(doc, err)
+	}
+
+	if after == before {
+		fmt.Println("No changes")
+		return nil
+	}
+	if after.Message == "" {
+		if current.Message == "" && current.Emoji == "" {
+			fmt.Println("No changes")
+			return nil
+		}
+		return runClear()
+	}
+
+	return runSet(setOptions{
+		Message: after.Message,
+		Emoji:   after.Emoji,
+		Limited: after.Limited,
+		Expiry:  expiry,
+		OrgName: after.Org,
+	})
+}
+
+// editedExpiry works out the expiry to set. An untouched expiry keeps what
+// remains of the current one.
+func editedExpiry(current *status.Status, before, after editedStatus) (time.Duration, error) {
+	if after.Expiry != before.Expiry {
+		return parseExpiry(after.Expiry, time.Now())
+	}
+	if current.ExpiresAt == nil {
+		return 0, nil
+	}
+	d := time.Until(*current.ExpiresAt)
+	if d <= 0 {
+		return 0, errors.New("your status has expired since the file was opened; set a new expiry")
+	}
+	return d, nil
+}
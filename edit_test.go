@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func TestParseStatusDocument(t *testing.T) {
+	em := status.NewEmojiManager()
+	want := editedStatus{
+		Message: "on vacation",
+		Emoji:   "palm_tree",
+		Limited: true,
+		Expiry:  "never",
+	}
+
+	got, err := parseStatusDocument(em, formatStatusDocument(want))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got, err = parseStatusDocument(em, "# ERROR: earlier\n---\nemoji: :pizza:\nlimited: false\nexpiry: \"fri 17:00\"\norg:\n---\n\nlunch\n  at noon\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want = editedStatus{Message: "lunch at noon", Emoji: "pizza", Expiry: "fri 17:00"}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	want = editedStatus{Message: "#hackweek demos", Emoji: "tada", Expiry: "never"}
+	got, err = parseStatusDocument(em, formatStatusDocument(want))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != want {
+		t.Errorf("expected a message starting with # to be kept, got %+v", got)
+	}
+
+	got, err = parseStatusDocument(em, "---\nemoji: pizza\nlimited: false\nexpiry: never\n---\n#hackweek\nlunch\n\n# a note\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Message != "#hackweek lunch" {
+		t.Errorf("expected comments only after the message, got %q", got.Message)
+	}
+}
+
+func TestParseStatusDocumentErrors(t *testing.T) {
+	em := status.NewEmojiManager()
+	tests := map[string]string{
+		"lunch\n": "must start with ---",
+		"---\nemoji: pizza\nlimited: false\nexpiry: never\nlunch\n":                         "key: value",
+		"---\nemoji: pizza\nlimited: false\nexpiry: never\n":                                "must end with ---",
+		"---\nemoji: nope\nlimited: false\nexpiry: never\n---\nlunch\n":                     "unknown emoji",
+		"---\nemoji: pizza\nlimited: maybe\nexpiry: never\n---\nlunch\n":                    "true or false",
+		"---\nemoji: pizza\nemoji: pizza\nlimited: false\nexpiry: never\n---\nlunch\n":      "given twice",
+		"---\nemoji: pizza\nlimited: false\nexpiry: never\nmood: good\n---\nlunch\n":        "unknown front matter field",
+		"---\nemoji: pizza\nlimited: false\n---\nlunch\n":                                   "expiry is missing",
+		"---\nemoji: pizza\nlimited: false\nexpiry: never\n---\n" + strings.Repeat("a", 81): "at most 80",
+	}
+	for doc, want := range tests {
+		_, err := parseStatusDocument(em, doc)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected an error containing %q, got %v", doc, want, err)
+		}
+	}
+
+	if _, err := parseStatusDocument(em, "\n# only comments\n\n"); err != errEmptyDocument {
+		t.Errorf("expected errEmptyDocument, got %v", err)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2021, 6, 4, 16, 0, 0, 0, time.Local)
+	tests := map[string]time.Duration{
+		"never":            0,
+		"":                 0,
+		"2h":               2 * time.Hour,
+		"3d":               3 * 24 * time.Hour,
+		"2021-06-04 18:30": 150 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := parseExpiry(in, now)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", in, err)
+		} else if got != want {
+			t.Errorf("%q: got %s, want %s", in, got, want)
+		}
+	}
+	for _, in := range []string{"soon", "2021-06-04 09:00"} {
+		if _, err := parseExpiry(in, now); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+// editWith sets the editor to a shell script running script on the file.
+func editWith(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "editor.sh")
+	if err := ioutil.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "GH_EDITOR", "sh "+path)
+}
+
+func TestEdit(t *testing.T) {
+	setupTest(t, "get_viewer", "set_edited")
+	editWith(t, `sed -i 's/vacation/holiday/' "$1"`)
+
+	out, err := runCommand(t, "edit")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🌴 on holiday\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestEditReopensOnError(t *testing.T) {
+	setupTest(t, "get_viewer", "set_edited")
+	editWith(t, `if grep -q '^# ERROR: unknown emoji "nope"' "$1"; then
+	sed -i 's/^emoji: .*/emoji: palm_tree/' "$1"
+else
+	sed -i 's/^emoji: .*/emoji: nope/; s/vacation/holiday/' "$1"
+fi`)
+
+	out, err := runCommand(t, "edit")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🌴 on holiday\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestEditEmptyFile(t *testing.T) {
+	f := setupTest(t, "get_viewer")
+	editWith(t, `: > "$1"`)
+
+	out, err := runCommand(t, "edit")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "Empty file; leaving your status alone\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected only the status to be fetched, got %q", f.calls)
+	}
+}
+
+func TestEditUnchanged(t *testing.T) {
+	for _, fixture := range []string{"get_viewer", "get_viewer_hash", "get_viewer_emoji_only"} {
+		t.Run(fixture, func(t *testing.T) {
+			f := setupTest(t, fixture)
+			editWith(t, "true")
+
+			out, err := runCommand(t, "edit")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if want := "No changes\n"; out != want {
+				t.Errorf("got %q, want %q", out, want)
+			}
+			if len(f.calls) != 1 {
+				t.Errorf("expected the status to be left alone, got %q", f.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// editorCommand returns the user's editor, preferring the same settings as gh.
+func editorCommand() string {
+	for _, name := range []string{"GH_EDITOR", "VISUAL", "EDITOR"} {
+		if e := os.Getenv(name); e != "" {
+			return e
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "nano"
+}
+
+// openEditor opens text in the user's editor in a temporary file called name,
+// and returns the file's contents once the editor exits.
+func openEditor(name, text string) (string, error) {
+	dir, err := ioutil.TempDir("", "gh-user-status")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		return "", err
+	}
+
+	args := strings.Fields(editorCommand())
+	if len(args) == 0 {
+		return "", errors.New("no editor is set")
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor failed: %w", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateFixtures = flag.Bool("update", false, "rewrite the requests recorded in testdata/graphql")
+
+// anyValue in a fixture's variables matches whatever value was sent, for
+// values like expiry times that change from run to run.
+const anyValue = "<any>"
+
+// ghFixture is a recorded `gh api graphql` call: the request we expect to
+// make and what gh answered with.
+type ghFixture struct {
+	Request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables,omitempty"`
+	} `json:"request"`
+	Response json.RawMessage `json:"response"`
+	// ExitCode and Stderr are what gh exits with and prints besides the
+	// response, e.g. for GraphQL errors.
+	ExitCode int    `json:"exitCode,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+}
+
+// fakeGH is a status.Executor that replays fixtures from testdata/graphql in
+// order, failing the test if a request doesn't match the next fixture. Every
+// call is recorded, including ones that aren't GraphQL requests, such as
+// `gh auth refresh`, which succeed without doing anything.
+type fakeGH struct {
+	t        *testing.T
+	names    []string
+	fixtures []ghFixture
+	calls    [][]string
+}
+
+// newFakeGH installs a fake gh that expects one request per named fixture,
+// and checks that they were all made when the test finishes.
+func newFakeGH(t *testing.T, names ...string) *fakeGH {
+	t.Helper()
+	f := &fakeGH{t: t, names: names}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(fixturePath(name))
+		if err != nil {
+			t.Fatalf("could not read fixture: %s", err)
+		}
+		var fx ghFixture
+		if err := json.Unmarshal(data, &fx); err != nil {
+			t.Fatalf("could not parse fixture %s: %s", name, err)
+		}
+		f.fixtures = append(f.fixtures, fx)
+	}
+
+	prev := apiClient.Exec
+	apiClient.Exec = f
+	t.Cleanup(func() {
+		apiClient.Exec = prev
+		if n := len(f.graphQLCalls()); n < len(f.fixtures) {
+			t.Errorf("expected %d GraphQL requests, got %d", len(f.fixtures), n)
+		}
+	})
+
+	return f
+}
+
+func fixturePath(name string) string {
+	return filepath.Join("testdata", "graphql", name+".json")
+}
+
+// graphQLCalls returns the arguments of every `gh api graphql` call made.
+func (f *fakeGH) graphQLCalls() [][]string {
+	calls := [][]string{}
+	for _, c := range f.calls {
+		if len(c) >= 2 && c[0] == "api" && c[1] == "graphql" {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (f *fakeGH) Run(stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	f.calls = append(f.calls, args)
+	if len(args) < 2 || args[0] != "api" || args[1] != "graphql" {
+		return nil
+	}
+
+	i := len(f.graphQLCalls()) - 1
+	if i >= len(f.fixtures) {
+		f.t.Errorf("unexpected GraphQL request: %q", args)
+		return errors.New("unexpected request")
+	}
+	fx := f.fixtures[i]
+
+	query, variables, err := readGraphQLRequest(args[2:], stdin)
+	if err != nil {
+		f.t.Errorf("could not read GraphQL request: %s", err)
+		return err
+	}
+	if *updateFixtures {
+		f.record(i, query, variables)
+	} else {
+		if normalizeQuery(query) != normalizeQuery(fx.Request.Query) {
+			f.t.Errorf("request %d doesn't match %s:\n got: %s\nwant: %s",
+				i, f.names[i], normalizeQuery(query), normalizeQuery(fx.Request.Query))
+		}
+		if !variablesMatch(fx.Request.Variables, variables) {
+			f.t.Errorf("request %d variables don't match %s:\n got: %v\nwant: %v",
+				i, f.names[i], variables, fx.Request.Variables)
+		}
+	}
+
+	_, _ = stdout.Write(fx.Response)
+	_, _ = io.WriteString(stderr, fx.Stderr)
+	if fx.ExitCode != 0 {
+		return fmt.Errorf("exit status %d", fx.ExitCode)
+	}
+	return nil
+}
+
+// record rewrites fixture i with the request that was actually made, keeping
+// wildcard variables as they are.
+func (f *fakeGH) record(i int, query string, variables map[string]interface{}) {
+	fx := f.fixtures[i]
+	for k, v := range fx.Request.Variables {
+		if v == anyValue {
+			variables[k] = anyValue
+		}
+	}
+	fx.Request.Query = query
+	fx.Request.Variables = variables
+	if len(variables) == 0 {
+		fx.Request.Variables = nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fx); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fixturePath(f.names[i]), buf.Bytes(), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// readGraphQLRequest reads the JSON body that `gh api graphql --input -` is
+// given on STDIN.
+func readGraphQLRequest(args []string, stdin io.Reader) (string, map[string]interface{}, error) {
+	if want := []string{"--input", "-"}; !reflect.DeepEqual(args, want) {
+		return "", nil, fmt.Errorf("expected arguments %q, got %q", want, args)
+	}
+	var req struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		return "", nil, err
+	}
+	if req.Variables == nil {
+		req.Variables = map[string]interface{}{}
+	}
+	return req.Query, req.Variables, nil
+}
+
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(q), " ")
+}
+
+func variablesMatch(want, got map[string]interface{}) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			return false
+		}
+		if w != anyValue && !reflect.DeepEqual(w, g) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+type focusOptions struct {
+	Duration     time.Duration
+	Message      string
+	Emoji        string
+	Cycles       int
+	Break        time.Duration
+	BreakMessage string
+	BreakEmoji   string
+}
+
+func focusCmd() *cobra.Command {
+	opts := focusOptions{}
+	cmd := &cobra.Command{
+		Use:   "focus <duration> [<status>]",
+		Short: "hold a focus status for a while, then put your old one back",
+		Long: `Set a limited availability status that expires after the given duration and
+show a countdown until it is over.
+
+With --cycles, focus periods alternate with breaks of --break, pomodoro style,
+and each period sets its own status. When the last period ends, or on Ctrl-C,
+whatever status you had before is restored.`,
+		Example: `  gh user-status focus 50m "deep work"
+  gh user-status focus 25m --cycles 4 --break 5m`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := time.ParseDuration(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid duration %q: %w", args[0], err)
+			}
+			if d <= 0 {
+				return errors.New("duration must be positive")
+			}
+			opts.Duration = d
+			if len(args) > 1 {
+				opts.Message = args[1]
+			}
+			if opts.Cycles < 1 {
+				return errors.New("--cycles must be at least 1")
+			}
+			return runFocus(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Emoji, "emoji", "e", "tomato", "Emoji for the focus status")
+	cmd.Flags().IntVarP(&opts.Cycles, "cycles", "c", 1, "Number of focus periods")
+	cmd.Flags().DurationVarP(&opts.Break, "break", "b", 5*time.Minute, "Length of the breaks between focus periods")
+	cmd.Flags().StringVar(&opts.BreakMessage, "break-message", "on a short break", "Status to show during breaks")
+	cmd.Flags().StringVar(&opts.BreakEmoji, "break-emoji", "coffee", "Emoji to show during breaks")
+
+	return cmd
+}
+
+func runFocus(opts focusOptions) error {
+	if opts.Message == "" {
+		opts.Message = "focusing"
+	}
+
+	prev, err := apiStatus("")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	em := status.NewEmojiManager()
+	for cycle := 1; cycle <= opts.Cycles && ctx.Err() == nil; cycle++ {
+		err = focusPeriod(ctx, em, setOptions{
+			Message: opts.Message,
+			Emoji:   opts.Emoji,
+			Limited: true,
+			Expiry:  opts.Duration,
+		}, fmt.Sprintf("focus %d/%d", cycle, opts.Cycles))
+		if err != nil || cycle == opts.Cycles || ctx.Err() != nil {
+			break
+		}
+
+		err = focusPeriod(ctx, em, setOptions{
+			Message: opts.BreakMessage,
+			Emoji:   opts.BreakEmoji,
+			Expiry:  opts.Break,
+		}, "break")
+		if err != nil {
+			break
+		}
+	}
+
+	fmt.Println("Restoring your previous status")
+	if restoreErr := restoreStatus(prev); err == nil {
+		err = restoreErr
+	}
+
+	return err
+}
+
+// focusPeriod sets the status in opts and counts down until it expires or
+// ctx is cancelled.
+func focusPeriod(ctx context.Context, em status.EmojiManager, opts setOptions, label string) error {
+	if err := runSet(opts); err != nil {
+		return err
+	}
+
+	end := time.Now().Add(opts.Expiry)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer fmt.Println()
+
+	for {
+		remaining := time.Until(end).Round(time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		fmt.Printf("\r%s %s: %s remaining ", em.ReplaceAll(":"+opts.Emoji+":"), label, formatCountdown(remaining))
+		if remaining == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func formatCountdown(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// fileStatus is a status described by the first line of a followed file.
+type fileStatus struct {
+	Message string
+	Emoji   string
+	Limited bool
+	Expiry  time.Duration
+}
+
+// parseStatusLine parses a line like ":coffee: on a break !limited @30m". The
+// leading emoji and the trailing !limited and @<duration> markers are all
+// optional; a leading :word: that isn't a known emoji is kept in the message.
+// An empty line parses to an empty status, but a line of nothing but an emoji
+// and markers is an error rather than a way to clear the status.
+func parseStatusLine(em status.EmojiManager, line string) (fileStatus, error) {
+	fs := fileStatus{Emoji: "thought_balloon"}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fs, nil
+	}
+
+	if len(fields) > 0 {
+		first := fields[0]
+		if len(first) > 2 && strings.HasPrefix(first, ":") && strings.HasSuffix(first, ":") {
+			if _, ok := em.Lookup(first); ok {
+				fs.Emoji = strings.Trim(first, ":")
+				fields = fields[1:]
+			}
+		}
+	}
+
+	for len(fields) > 0 {
+		last := fields[len(fields)-1]
+		switch {
+		case last == "!limited":
+			fs.Limited = true
+		case strings.HasPrefix(last, "@") && len(last) > 1:
+			d, err := status.ParseDuration(last[1:])
+			if err != nil {
+				return fs, fmt.Errorf("invalid expiry %q", last)
+			}
+			fs.Expiry = d
+		default:
+			fs.Message = strings.Join(fields, " ")
+			return fs, nil
+		}
+		fields = fields[:len(fields)-1]
+	}
+
+	return fs, fmt.Errorf("%q has no status message; empty the line to clear your status", line)
+}
+
+type followFileOptions struct {
+	Path     string
+	Interval time.Duration
+	Debounce time.Duration
+}
+
+func followFileCmd() *cobra.Command {
+	opts := followFileOptions{}
+	cmd := &cobra.Command{
+		Use:   "follow-file <path>",
+		Short: "keep your status in sync with a file",
+		Long: fmt.Sprintf(`Run until interrupted, setting your status from the first line of a file
+whenever it changes. Emptying the line, or deleting the file, clears your
+status. A line GitHub would reject is reported once; if the request to change
+your status fails, it is tried again every %s.
+
+The line is the status message, optionally starting with an :emoji: and ending
+with !limited to indicate limited availability and @<duration> to set an
+expiry, for example:
+
+  :coffee: grabbing a coffee !limited @15m
+
+Writes are debounced, and the status is only changed when the line does.`, followRetryDelay),
+		Example: `  gh user-status follow-file ~/.status &
+  echo ':pizza: lunch @1h' > ~/.status`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Path = args[0]
+			return runFollowFile(opts)
+		},
+	}
+	cmd.Flags().DurationVarP(&opts.Interval, "interval", "i", 500*time.Millisecond, "How often to check the file for changes")
+	cmd.Flags().DurationVarP(&opts.Debounce, "debounce", "d", time.Second, "How long the file must be unchanged before it is applied")
+
+	return cmd
+}
+
+// readStatusLine returns the first line of the file at path, or "" if the file
+// is missing or empty.
+func readStatusLine(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text()), scanner.Err()
+}
+
+func fileStamp(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size())
+}
+
+// followRetryDelay is how long follow-file waits before trying again to change
+// the status after failing to.
+const followRetryDelay = 10 * time.Second
+
+// statusFollower sets the viewer's status from the first line of a file.
+type statusFollower struct {
+	path    string
+	em      status.EmojiManager
+	current *status.Status
+	// lastLine is the line last applied; nil until the file is first read.
+	lastLine *string
+	// retryAt is when to apply the file again after failing to change the
+	// status, or zero if the last change succeeded.
+	retryAt time.Time
+}
+
+// apply changes the status to match the file, if it changed since the last
+// time it was applied.
+func (f *statusFollower) apply(now time.Time) {
+	f.retryAt = time.Time{}
+	line, err := readStatusLine(f.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s\n", timestamp(), err)
+		return
+	}
+	if f.lastLine != nil && line == *f.lastLine {
+		return
+	}
+	fs, err := parseStatusLine(f.em, line)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s\n", timestamp(), err)
+		return
+	}
+
+	// Don't re-set the live status just because we started following.
+	current := f.current
+	if f.lastLine == nil && fs.Message == current.Message &&
+		(fs.Message == "" || (":"+fs.Emoji+":" == current.Emoji && fs.Limited == current.IndicatesLimitedAvailability)) {
+		f.lastLine = &line
+		return
+	}
+
+	// A status GitHub won't accept is reported once, like a line that
+	// doesn't parse; only failed requests are worth trying again.
+	if fs.Message != "" {
+		if err := validateStatus(f.em, fs.Emoji, fs.Message); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s\n", timestamp(), err)
+			return
+		}
+	}
+
+	if fs.Message == "" {
+		err = runClear()
+	} else {
+		err = runSet(setOptions{
+			Message: fs.Message,
+			Emoji:   fs.Emoji,
+			Limited: fs.Limited,
+			Expiry:  fs.Expiry,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s; trying again in %s\n", timestamp(), err, followRetryDelay)
+		f.retryAt = now.Add(followRetryDelay)
+		return
+	}
+	f.lastLine = &line
+}
+
+// retryDue reports whether a failed change should be tried again at now.
+func (f *statusFollower) retryDue(now time.Time) bool {
+	return !f.retryAt.IsZero() && !now.Before(f.retryAt)
+}
+
+func runFollowFile(opts followFileOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	current, err := apiStatus("")
+	if err != nil {
+		return err
+	}
+	f := &statusFollower{path: opts.Path, em: status.NewEmojiManager(), current: current}
+
+	f.apply(time.Now())
+	seen := fileStamp(opts.Path)
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+
+		if stamp := fileStamp(opts.Path); stamp != seen {
+			seen = stamp
+			changedAt = time.Now()
+			continue
+		}
+		now := time.Now()
+		if (!changedAt.IsZero() && now.Sub(changedAt) >= opts.Debounce) || f.retryDue(now) {
+			changedAt = time.Time{}
+			f.apply(now)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func TestParseStatusLine(t *testing.T) {
+	em := status.NewEmojiManager()
+	tests := []struct {
+		line string
+		want fileStatus
+		err  string
+	}{
+		{line: "", want: fileStatus{Emoji: "thought_balloon"}},
+		{line: "lunch", want: fileStatus{Message: "lunch", Emoji: "thought_balloon"}},
+		{line: ":coffee: grabbing a coffee !limited @15m",
+			want: fileStatus{Message: "grabbing a coffee", Emoji: "coffee", Limited: true, Expiry: 15 * time.Minute}},
+		{line: ":notanemoji: hello", want: fileStatus{Message: ":notanemoji: hello", Emoji: "thought_balloon"}},
+		{line: "meeting @soon", err: `invalid expiry "@soon"`},
+		{line: ":coffee: !limited", err: "no status message"},
+		{line: "@1h", err: "no status message"},
+	}
+	for _, tt := range tests {
+		got, err := parseStatusLine(em, tt.line)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: expected error %q, got %v", tt.line, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.line, err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func newTestFollower(t *testing.T, line string) *statusFollower {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "status")
+	if err := ioutil.WriteFile(path, []byte(line+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &statusFollower{path: path, em: status.NewEmojiManager(), current: &status.Status{Message: "before"}}
+}
+
+func TestStatusFollowerRejectsLineWithoutMessage(t *testing.T) {
+	f := setupTest(t)
+	follower := newTestFollower(t, ":coffee: !limited")
+
+	follower.apply(time.Now())
+	if len(f.calls) != 0 {
+		t.Errorf("expected the status to be left alone, got %q", f.calls)
+	}
+	if !follower.retryAt.IsZero() {
+		t.Errorf("expected an invalid line not to be retried")
+	}
+}
+
+func TestStatusFollowerRetriesFailedChange(t *testing.T) {
+	setupTest(t, "set_insufficient_scopes", "set_thought_balloon")
+	follower := newTestFollower(t, "lunch")
+
+	now := time.Now()
+	follower.apply(now)
+	if follower.lastLine != nil {
+		t.Errorf("expected the failed line not to count as applied")
+	}
+	if follower.retryDue(now.Add(followRetryDelay - time.Second)) {
+		t.Errorf("expected to wait before retrying")
+	}
+	if !follower.retryDue(now.Add(followRetryDelay)) {
+		t.Fatalf("expected a retry to be due after %s", followRetryDelay)
+	}
+
+	follower.apply(now.Add(followRetryDelay))
+	if follower.lastLine == nil || *follower.lastLine != "lunch" {
+		t.Errorf("expected the retry to apply the line, got %v", follower.lastLine)
+	}
+	if follower.retryDue(now.Add(time.Hour)) {
+		t.Errorf("expected no further retries")
+	}
+}
+
+func TestStatusFollowerDoesNotRetryInvalidStatus(t *testing.T) {
+	f := setupTest(t)
+	follower := newTestFollower(t, strings.Repeat("x", 81))
+
+	follower.apply(time.Now())
+	if len(f.calls) != 0 {
+		t.Errorf("expected no requests, got %q", f.calls)
+	}
+	if !follower.retryAt.IsZero() {
+		t.Errorf("expected a message GitHub won't accept not to be retried")
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// gitHookNames are the hooks that git-hook install manages.
+var gitHookNames = []string{"post-checkout", "post-commit"}
+
+const (
+	gitHookBegin = "# >>> gh-user-status >>>"
+	gitHookEnd   = "# <<< gh-user-status <<<"
+)
+
+// gitHookBlock is appended to each hook. It runs in the background with no
+// input or output so that it can neither slow down nor fail the git command.
+func gitHookBlock(hook string) string {
+	return fmt.Sprintf(`%s
+(gh user-status git-hook run %s "$@" </dev/null >/dev/null 2>&1 &)
+%s
+`, gitHookBegin, hook, gitHookEnd)
+}
+
+// gitRule maps branches matching the glob Branch to a status.
+type gitRule struct {
+	Branch  string
+	Emoji   string
+	Message string
+}
+
+type gitRules struct {
+	Rules []gitRule
+	// Expiry is how long a status lasts after the last checkout or commit,
+	// e.g. "30m".
+	Expiry string
+}
+
+var defaultGitRules = gitRules{
+	Rules: []gitRule{
+		{Branch: "*", Emoji: "hammer_and_wrench", Message: "hacking on {{ref}} (branch {{branch}})"},
+	},
+	Expiry: "30m",
+}
+
+// gitHookState remembers the status the hook last set, so that it never
+// replaces a status set some other way.
+type gitHookState struct {
+	Message string
+	Emoji   string
+}
+
+func gitHookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git-hook",
+		Short: "set your status from git activity",
+		Long: `Install git hooks that set your status whenever you check out a branch or
+commit, for example "hacking on owner/repo#123 (branch 123-fix-x)".
+
+Each status expires shortly after your last checkout or commit, so it fades
+when you stop working. A status you set some other way is never replaced.
+
+Statuses are chosen by the first rule whose branch glob matches the current
+branch. Rules are read from git-rules.json in the extension's config
+directory:
+
+  {
+    "rules": [
+      {"branch": "fix/*", "emoji": "bug", "message": "fixing {{ref}}"},
+      {"branch": "*", "emoji": "hammer_and_wrench", "message": "hacking on {{ref}} (branch {{branch}})"}
+    ],
+    "expiry": "30m"
+  }
+
+Messages may use {{repo}} (owner/repo), {{branch}}, {{issue}} (the first
+number in the branch name) and {{ref}} (owner/repo#issue, or owner/repo when
+the branch has no number).`,
+	}
+	cmd.AddCommand(gitHookInstallCmd())
+	cmd.AddCommand(gitHookUninstallCmd())
+	cmd.AddCommand(gitHookRunCmd())
+
+	return cmd
+}
+
+func gitHookInstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "install",
+		Short: "install status hooks into the current repository",
+		Long: `Install post-checkout and post-commit hooks into the current repository.
+
+Existing hooks are added to only when they are sh or bash scripts that don't
+end by exiting. Otherwise nothing is installed, and you can call
+"gh user-status git-hook run <hook>" from your hooks yourself.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGitHookInstall()
+		},
+	}
+}
+
+func gitHookUninstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall",
+		Short: "remove status hooks from the current repository",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGitHookUninstall()
+		},
+	}
+}
+
+func gitHookRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "run <hook> [<hook args>...]",
+		Short:  "set your status for a git hook",
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// A hook must never fail the git operation that triggered it.
+			if err := runGitHook(args[0], args[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gh user-status: %s\n", err)
+			}
+			return nil
+		},
+	}
+}
+
+func gitHooksDir() (string, error) {
+	dir, err := gitOutput("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", errors.New("not in a git repository")
+	}
+	return dir, nil
+}
+
+func runGitHookInstall() error {
+	dir, err := gitHooksDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Check every hook before changing any, so that a refusal doesn't leave
+	// the repository half set up.
+	contents := map[string]string{}
+	for _, hook := range gitHookNames {
+		p := filepath.Join(dir, hook)
+		existing, err := ioutil.ReadFile(p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		content := string(existing)
+		if strings.Contains(content, gitHookBegin) {
+			continue
+		}
+		if content == "" {
+			content = "#!/bin/sh\n"
+		} else if err := checkAppendableHook(content); err != nil {
+			return fmt.Errorf("not changing %s: %s; call `gh user-status git-hook run %s \"$@\"` from it in the background yourself", p, err, hook)
+		} else if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		contents[hook] = content + gitHookBlock(hook)
+	}
+
+	for _, hook := range gitHookNames {
+		content, ok := contents[hook]
+		if !ok {
+			continue
+		}
+		p := filepath.Join(dir, hook)
+		if err := ioutil.WriteFile(p, []byte(content), 0755); err != nil {
+			return err
+		}
+		if err := os.Chmod(p, 0755); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✓ Installed %s hooks in %s\n", strings.Join(gitHookNames, " and "), dir)
+
+	return nil
+}
+
+var (
+	shellShebangRE = regexp.MustCompile(`^#!\s*(/usr/bin/env\s+|/usr)?(/bin/)?(sh|bash|dash)(\s|$)`)
+	hookExitRE     = regexp.MustCompile(`^(exit|exec)(\s|$)`)
+)
+
+// checkAppendableHook reports why the gh-user-status block can't be appended
+// to an existing hook: it must be a shell script, and its last command must
+// not exit before reaching the block.
+func checkAppendableHook(content string) error {
+	lines := strings.Split(content, "\n")
+	if !shellShebangRE.MatchString(lines[0]) {
+		return errors.New("it isn't a sh or bash script")
+	}
+	for i := len(lines) - 1; i > 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hookExitRE.MatchString(line) {
+			return errors.New("it ends by exiting")
+		}
+		break
+	}
+	return nil
+}
+
+func runGitHookUninstall() error {
+	dir, err := gitHooksDir()
+	if err != nil {
+		return err
+	}
+
+	blockRE := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(gitHookBegin) + `.*?` + regexp.QuoteMeta(gitHookEnd) + `\n?`)
+	for _, hook := range gitHookNames {
+		p := filepath.Join(dir, hook)
+		existing, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		content := blockRE.ReplaceAllString(string(existing), "")
+		if strings.TrimSpace(content) == "#!/bin/sh" {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0755); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✓ Removed hooks from %s\n", dir)
+
+	return nil
+}
+
+func runGitHook(hook string, args []string) error {
+	// post-checkout's third argument is 0 for file checkouts, which don't
+	// change what we're working on.
+	if hook == "post-checkout" && len(args) >= 3 && args[2] == "0" {
+		return nil
+	}
+
+	rulesPath, err := configPath("git-rules.json")
+	if err != nil {
+		return err
+	}
+	rules := gitRules{Expiry: defaultGitRules.Expiry}
+	if err := readJSONFile(rulesPath, &rules); err != nil {
+		return err
+	}
+	if len(rules.Rules) == 0 {
+		rules.Rules = defaultGitRules.Rules
+	}
+	expiry, err := time.ParseDuration(rules.Expiry)
+	if err != nil {
+		return fmt.Errorf("invalid expiry %q in %s", rules.Expiry, rulesPath)
+	}
+
+	branch, err := gitOutput("symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		// Detached HEAD, e.g. mid-rebase.
+		return nil
+	}
+	repo, err := gitRepoName()
+	if err != nil {
+		return err
+	}
+
+	var rule *gitRule
+	for i, r := range rules.Rules {
+		if ok, _ := path.Match(r.Branch, branch); ok {
+			rule = &rules.Rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return nil
+	}
+
+	statePath, err := configPath("git-hook-state.json")
+	if err != nil {
+		return err
+	}
+	state := gitHookState{}
+	if err := readJSONFile(statePath, &state); err != nil {
+		return err
+	}
+	current, err := apiStatus("")
+	if err != nil {
+		return err
+	}
+	if current.Message != "" && (current.Message != state.Message || current.Emoji != state.Emoji) {
+		return nil
+	}
+
+	opts := setOptions{
+		Message: expandGitMessage(rule.Message, repo, branch),
+		Emoji:   rule.Emoji,
+		Expiry:  expiry,
+	}
+	if err := runSet(opts); err != nil {
+		return err
+	}
+
+	return writeJSONFile(statePath, gitHookState{Message: opts.Message, Emoji: fmt.Sprintf(":%s:", opts.Emoji)})
+}
+
+var branchIssueRE = regexp.MustCompile(`(?:^|\D)(\d+)(?:\D|$)`)
+
+// branchRef finds the issue number in a branch name and the owner/repo#issue
+// reference for it, which is just repo when there's no number.
+func branchRef(repo, branch string) (issue, ref string) {
+	if m := branchIssueRE.FindStringSubmatch(branch); m != nil {
+		issue = m[1]
+	}
+	ref = repo
+	if issue != "" {
+		ref = fmt.Sprintf("%s#%s", repo, issue)
+	}
+	return issue, ref
+}
+
+func expandGitMessage(tmpl, repo, branch string) string {
+	issue, ref := branchRef(repo, branch)
+	return strings.NewReplacer(
+		"{{repo}}", repo,
+		"{{branch}}", branch,
+		"{{issue}}", issue,
+		"{{ref}}", ref,
+	).Replace(tmpl)
+}
+
+var remoteRE = regexp.MustCompile(`[:/]([^/:]+/[^/]+?)(?:\.git)?/?$`)
+
+// gitRepoName returns owner/repo for the origin remote.
+func gitRepoName() (string, error) {
+	url, err := gitOutput("remote", "get-url", "origin")
+	if err != nil {
+		return "", errors.New("could not find the origin remote")
+	}
+	m := remoteRE.FindStringSubmatch(url)
+	if m == nil {
+		return "", fmt.Errorf("could not tell the repository from remote %s", url)
+	}
+	return m[1], nil
+}
+
+// gitOutput runs git in the current directory and returns its trimmed output.
+func gitOutput(args ...string) (string, error) {
+	var out, eout bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &eout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to run git. error: %w, stderr: %s", err, eout.String())
+	}
+	return strings.TrimSpace(out.String()), nil
+}
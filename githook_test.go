@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupGitRepo changes into a new git repository, returning its hooks
+// directory.
+func setupGitRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Skipf("git init failed: %s: %s", err, out)
+	}
+	prev, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(prev) })
+
+	hooks := filepath.Join(dir, ".git", "hooks")
+	if err := os.MkdirAll(hooks, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return hooks
+}
+
+func writeHook(t *testing.T, hooks, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(hooks, name), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readHook(t *testing.T, hooks, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(hooks, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGitHookInstall(t *testing.T) {
+	setupTest(t)
+	hooks := setupGitRepo(t)
+	existing := "#!/usr/bin/env bash\nmake lint\n"
+	writeHook(t, hooks, "post-commit", existing)
+
+	if _, err := runCommand(t, "git-hook", "install"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := readHook(t, hooks, "post-checkout"); got != "#!/bin/sh\n"+gitHookBlock("post-checkout") {
+		t.Errorf("unexpected post-checkout hook:\n%s", got)
+	}
+	if got := readHook(t, hooks, "post-commit"); got != existing+gitHookBlock("post-commit") {
+		t.Errorf("unexpected post-commit hook:\n%s", got)
+	}
+
+	if _, err := runCommand(t, "git-hook", "uninstall"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(hooks, "post-checkout")); !os.IsNotExist(err) {
+		t.Errorf("expected the post-checkout hook to be removed, got %v", err)
+	}
+	if got := readHook(t, hooks, "post-commit"); got != existing {
+		t.Errorf("expected the post-commit hook to be restored, got:\n%s", got)
+	}
+}
+
+func TestGitHookInstallRefusesUnsafeHooks(t *testing.T) {
+	tests := map[string]string{
+		"python":     "#!/usr/bin/env python3\nprint('hi')\n",
+		"node":       "#!/usr/bin/env node\nconsole.log('hi')\n",
+		"no shebang": "make lint\n",
+		"exits":      "#!/bin/sh\nmake lint\nexit 0\n\n# done\n",
+		"execs":      "#!/bin/bash\nexec make lint\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			setupTest(t)
+			hooks := setupGitRepo(t)
+			writeHook(t, hooks, "post-commit", content)
+
+			_, err := runCommand(t, "git-hook", "install")
+			if err == nil || !strings.Contains(err.Error(), "git-hook run post-commit") {
+				t.Errorf("expected the hook to be refused, got %v", err)
+			}
+			if got := readHook(t, hooks, "post-commit"); got != content {
+				t.Errorf("expected the hook to be left alone, got:\n%s", got)
+			}
+			if _, err := os.Stat(filepath.Join(hooks, "post-checkout")); !os.IsNotExist(err) {
+				t.Errorf("expected no hooks to be installed, got %v", err)
+			}
+		})
+	}
+}
@@ -6,4 +6,5 @@ require (
 	github.com/AlecAivazis/survey/v2 v2.2.16
 	github.com/cli/safeexec v1.0.0
 	github.com/spf13/cobra v1.2.1
+	golang.org/x/term v0.0.0-20210503060354-a79de5458b56
 )
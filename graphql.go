@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+	"golang.org/x/term"
+)
+
+// apiClient talks to GitHub through gh, counting requests for /metrics. Tests
+// replace its Exec with a fake gh.
+var apiClient = &status.Client{
+	Exec: status.GHExecutor{},
+	Observe: func(query string, d time.Duration, err error) {
+		apiStats.observe(operationName(query), d, err)
+	},
+}
+
+// graphQL runs query with apiClient; see status.Client.GraphQL.
+func graphQL(query string, variables map[string]interface{}, data interface{}) error {
+	return apiClient.GraphQL(query, variables, data)
+}
+
+// ghWithInput runs gh, connecting IO handles for user input
+func ghWithInput(args ...string) error {
+	return apiClient.Exec.Run(os.Stdin, os.Stdout, os.Stderr, args...)
+}
+
+var errScopeDeclined = errors.New("this extension requires the 'user' scope")
+
+// withUserScope runs fn and, if it fails because the token lacks the user
+// scope, offers to add the scope and then runs fn again.
+func withUserScope(fn func() error) error {
+	err := fn()
+	if !status.IsInsufficientScopes(err) {
+		return err
+	}
+
+	// Hooks and timers have nobody to answer the prompt.
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%w; run `gh auth refresh -s user` to add it", errScopeDeclined)
+	}
+
+	fmt.Println("! Sorry, this extension requires the 'user' scope.")
+	answer, err := prompts.Confirm("Would you like to add the user scope now?", true)
+	if err != nil {
+		return fmt.Errorf("could not prompt: %w", err)
+	}
+	if !answer {
+		return errScopeDeclined
+	}
+	if err = ghWithInput("auth", "refresh", "-s", "user"); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+// stdinIsTerminal reports whether there's somebody to prompt. It's a variable
+// so that tests can pretend there is.
+var stdinIsTerminal = func() bool {
+	return isTerminal(os.Stdin)
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// historyEntry records that Login's status changed to Status at Time.
+type historyEntry struct {
+	Time   time.Time
+	Login  string
+	Status *status.Status
+}
+
+const (
+	// historyRetention is how long changes are kept in the history.
+	historyRetention = 90 * 24 * time.Hour
+	// historyPruneSize is the size past which the history is pruned.
+	historyPruneSize = 1 << 20
+	// historyMaxEntries caps what pruning keeps when there have been a lot
+	// of changes within historyRetention.
+	historyMaxEntries = 5000
+)
+
+func historyFile() (string, error) {
+	return configPath("history.jsonl")
+}
+
+// appendHistory adds entries to the local status history, which watch keeps
+// so that report can show recent changes. Once the file grows past
+// historyPruneSize, changes older than historyRetention are dropped.
+func appendHistory(entries ...historyEntry) error {
+	path, err := historyFile()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if fi.Size() > historyPruneSize {
+		return pruneHistory(path, time.Now().Add(-historyRetention))
+	}
+	return nil
+}
+
+// pruneHistory atomically rewrites the history at path without the changes
+// before since, keeping at most historyMaxEntries of the newest.
+func pruneHistory(path string, since time.Time) error {
+	entries, err := readHistory(since)
+	if err != nil {
+		return err
+	}
+	if len(entries) > historyMaxEntries {
+		entries = entries[len(entries)-historyMaxEntries:]
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readHistory returns the recorded changes since the given time, oldest
+// first. It returns nothing if no history has been recorded.
+func readHistory(since time.Time) ([]historyEntry, error) {
+	path, err := historyFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []historyEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", path, line, err)
+		}
+		if e.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendarEvent is a VEVENT from an iCalendar file. Only the properties
+// needed to tell whether an event is in progress are kept.
+type calendarEvent struct {
+	UID          string
+	Summary      string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Cancelled    bool
+	RRule        *recurrenceRule
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+}
+
+// calendarOccurrence is a single instance of a possibly recurring event.
+type calendarOccurrence struct {
+	Event *calendarEvent
+	Start time.Time
+	End   time.Time
+}
+
+type recurrenceRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []byDay
+}
+
+// byDay is a BYDAY entry such as MO, 1MO (the first Monday) or -1FR (the last
+// Friday). Ordinal is zero for every such weekday.
+type byDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// parseICalendar reads the VEVENTs from an iCalendar (RFC 5545) stream.
+func parseICalendar(r io.Reader) ([]*calendarEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*calendarEvent{}
+	var cur *calendarEvent
+	var duration *time.Duration
+	hasEnd := false
+	for i, line := range lines {
+		prop, err := parseICalProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && prop.Value == "VEVENT":
+			cur = &calendarEvent{}
+			duration = nil
+			hasEnd = false
+			continue
+		case prop.Name == "END" && prop.Value == "VEVENT":
+			if cur == nil {
+				continue
+			}
+			if !hasEnd {
+				switch {
+				case duration != nil:
+					cur.End = cur.Start.Add(*duration)
+				case cur.AllDay:
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				default:
+					cur.End = cur.Start
+				}
+			}
+			events = append(events, cur)
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		switch prop.Name {
+		case "UID":
+			cur.UID = prop.Value
+		case "SUMMARY":
+			cur.Summary = unescapeICalText(prop.Value)
+		case "STATUS":
+			cur.Cancelled = strings.EqualFold(prop.Value, "CANCELLED")
+		case "DTSTART":
+			cur.Start, cur.AllDay, err = parseICalTime(prop)
+		case "DTEND":
+			cur.End, _, err = parseICalTime(prop)
+			hasEnd = true
+		case "DURATION":
+			var d time.Duration
+			d, err = parseICalDuration(prop.Value)
+			duration = &d
+		case "RRULE":
+			cur.RRule, err = parseRecurrenceRule(prop.Value)
+		case "EXDATE":
+			for _, v := range strings.Split(prop.Value, ",") {
+				var t time.Time
+				t, _, err = parseICalTime(icalProperty{Params: prop.Params, Value: v})
+				if err != nil {
+					break
+				}
+				cur.ExDates = append(cur.ExDates, t)
+			}
+		case "RECURRENCE-ID":
+			var t time.Time
+			t, _, err = parseICalTime(prop)
+			cur.RecurrenceID = &t
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+
+	return events, nil
+}
+
+// unfoldICalLines joins continuation lines, which begin with a space or tab,
+// onto the line before them.
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseICalProperty(line string) (icalProperty, error) {
+	prop := icalProperty{Params: map[string]string{}}
+
+	// The value starts at the first colon outside a quoted parameter value.
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("malformed content line %q", line)
+	}
+
+	prop.Value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		prop.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return prop, nil
+}
+
+func unescapeICalText(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// parseICalTime parses a DATE or DATE-TIME value, reporting whether it was a
+// DATE, which marks an all-day event.
+func parseICalTime(prop icalProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.Value)
+
+	loc := time.Local
+	if tzid, ok := prop.Params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	if prop.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+		}
+		return t, false, nil
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, false, nil
+}
+
+var icalDurationRE = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICalDuration(s string) (time.Duration, error) {
+	m := icalDurationRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRecurrenceRule(s string) (*recurrenceRule, error) {
+	rule := &recurrenceRule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.ToUpper(kv[0]), kv[1]
+		switch key {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid RRULE interval %q", value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE count %q", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			t, _, err := parseICalTime(icalProperty{Value: value})
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &t
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				bd, err := parseByDay(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, bd)
+			}
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported RRULE frequency %q", rule.Freq)
+	}
+
+	return rule, nil
+}
+
+func parseByDay(s string) (byDay, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return byDay{}, fmt.Errorf("invalid RRULE day %q", s)
+	}
+	wd, ok := icalWeekdays[s[len(s)-2:]]
+	if !ok {
+		return byDay{}, fmt.Errorf("invalid RRULE day %q", s)
+	}
+	bd := byDay{Weekday: wd}
+	if ordinal := s[:len(s)-2]; ordinal != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(ordinal, "+"))
+		if err != nil || n == 0 || n > 53 || n < -53 {
+			return byDay{}, fmt.Errorf("invalid RRULE day %q", s)
+		}
+		bd.Ordinal = n
+	}
+	return bd, nil
+}
+
+// maxOccurrences bounds how far a recurrence without COUNT or UNTIL is
+// expanded while looking for the current instance.
+const maxOccurrences = 100000
+
+// occurrencesAt returns every instance of the events that is in progress at
+// t, honouring RRULE, EXDATE and modified instances.
+func occurrencesAt(events []*calendarEvent, t time.Time) []calendarOccurrence {
+	// A modified instance replaces the instance of the master event it
+	// shares a UID and original start time with.
+	overridden := map[string]bool{}
+	for _, e := range events {
+		if e.RecurrenceID != nil {
+			overridden[e.UID+e.RecurrenceID.UTC().String()] = true
+		}
+	}
+
+	inProgress := func(start, end time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	}
+
+	found := []calendarOccurrence{}
+	for _, e := range events {
+		if e.Cancelled {
+			continue
+		}
+		length := e.End.Sub(e.Start)
+		if e.RRule == nil || e.RecurrenceID != nil {
+			if inProgress(e.Start, e.End) {
+				found = append(found, calendarOccurrence{Event: e, Start: e.Start, End: e.End})
+			}
+			continue
+		}
+
+		e.RRule.expand(e.Start, func(start time.Time) bool {
+			if start.After(t) {
+				return false
+			}
+			if overridden[e.UID+start.UTC().String()] || e.excluded(start) {
+				return true
+			}
+			end := start.Add(length)
+			if e.AllDay {
+				end = start.AddDate(0, 0, int(length.Hours()/24+0.5))
+			}
+			if inProgress(start, end) {
+				found = append(found, calendarOccurrence{Event: e, Start: start, End: end})
+			}
+			return true
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Start.Before(found[j].Start)
+	})
+
+	return found
+}
+
+func (e *calendarEvent) excluded(start time.Time) bool {
+	for _, ex := range e.ExDates {
+		if ex.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand calls fn with the start of each instance of the rule in order, until
+// the rule ends or fn returns false.
+func (r *recurrenceRule) expand(dtstart time.Time, fn func(time.Time) bool) {
+	emitted := 0
+	emit := func(start time.Time) bool {
+		if start.Before(dtstart) {
+			return true
+		}
+		if r.Until != nil && start.After(*r.Until) {
+			return false
+		}
+		if r.Count > 0 && emitted >= r.Count {
+			return false
+		}
+		emitted++
+		return fn(start)
+	}
+
+	// Ordinals only mean something for monthly and yearly rules, so daily
+	// and weekly ones go by the weekday alone.
+	hasDay := func(d time.Weekday) bool {
+		if len(r.ByDay) == 0 {
+			return true
+		}
+		for _, bd := range r.ByDay {
+			if bd.Weekday == d {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < maxOccurrences; i++ {
+		switch r.Freq {
+		case "DAILY":
+			start := dtstart.AddDate(0, 0, i*r.Interval)
+			if hasDay(start.Weekday()) && !emit(start) {
+				return
+			}
+		case "WEEKLY":
+			if len(r.ByDay) == 0 {
+				if !emit(dtstart.AddDate(0, 0, 7*i*r.Interval)) {
+					return
+				}
+				continue
+			}
+			// Weeks start on Monday, the RFC 5545 default.
+			offset := (int(dtstart.Weekday()) + 6) % 7
+			weekStart := dtstart.AddDate(0, 0, 7*i*r.Interval-offset)
+			for d := 0; d < 7; d++ {
+				start := weekStart.AddDate(0, 0, d)
+				if hasDay(start.Weekday()) && !emit(start) {
+					return
+				}
+			}
+		case "MONTHLY":
+			if len(r.ByDay) > 0 {
+				for _, start := range r.monthDays(dtstart, i*r.Interval) {
+					if !emit(start) {
+						return
+					}
+				}
+				continue
+			}
+			start := dtstart.AddDate(0, i*r.Interval, 0)
+			// Skip months that don't have the day, like the 31st of April.
+			if start.Day() == dtstart.Day() && !emit(start) {
+				return
+			}
+		case "YEARLY":
+			start := dtstart.AddDate(i*r.Interval, 0, 0)
+			if start.Day() == dtstart.Day() && !emit(start) {
+				return
+			}
+		}
+	}
+}
+
+// monthDays returns the days matching the rule's BYDAY in the month that is
+// months after DTSTART's, in order, at DTSTART's time of day.
+func (r *recurrenceRule) monthDays(dtstart time.Time, months int) []time.Time {
+	first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(months), 1,
+		dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+
+	matches := map[int]bool{}
+	for _, bd := range r.ByDay {
+		// The days of the month falling on the weekday, e.g. 7, 14, 21, 28.
+		days := []int{}
+		for d := 1 + (int(bd.Weekday)-int(first.Weekday())+7)%7; d <= daysInMonth; d += 7 {
+			days = append(days, d)
+		}
+		switch {
+		case bd.Ordinal == 0:
+			for _, d := range days {
+				matches[d] = true
+			}
+		case bd.Ordinal > 0 && bd.Ordinal <= len(days):
+			matches[days[bd.Ordinal-1]] = true
+		case bd.Ordinal < 0 && -bd.Ordinal <= len(days):
+			matches[days[len(days)+bd.Ordinal]] = true
+		}
+	}
+
+	starts := []time.Time{}
+	for d := 1; d <= daysInMonth; d++ {
+		if matches[d] {
+			starts = append(starts, first.AddDate(0, 0, d-1))
+		}
+	}
+	return starts
+}
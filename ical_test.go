@@ -0,0 +1,195 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// calendar wraps VEVENTs in a VCALENDAR.
+func calendar(events ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(events, "") + "END:VCALENDAR\r\n"
+}
+
+func local(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.Local)
+}
+
+func TestParseICalendar(t *testing.T) {
+	events, err := parseICalendar(strings.NewReader(calendar(
+		"BEGIN:VEVENT\r\nUID:1\r\nSUMMARY:Lunch\\, then a\r\n  walk\r\nDTSTART:20210607T120000\r\nDURATION:PT1H30M\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nUID:2\r\nSUMMARY:Holiday\r\nDTSTART;VALUE=DATE:20210607\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nUID:3\r\nSUMMARY:Standup\r\nDTSTART;TZID=\"UTC\":20210607T090000\r\nDTEND;TZID=\"UTC\":20210607T091500\r\nSTATUS:CANCELLED\r\nEND:VEVENT\r\n",
+	)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	lunch, holiday, standup := events[0], events[1], events[2]
+	if lunch.Summary != "Lunch, then a walk" {
+		t.Errorf("unexpected summary %q", lunch.Summary)
+	}
+	if want := local(2021, 6, 7, 13, 30); !lunch.End.Equal(want) {
+		t.Errorf("expected lunch to end at %s, got %s", want, lunch.End)
+	}
+	if !holiday.AllDay || !holiday.End.Equal(local(2021, 6, 8, 0, 0)) {
+		t.Errorf("expected a one-day all-day event, got %+v", holiday)
+	}
+	if !standup.Cancelled || !standup.Start.Equal(time.Date(2021, 6, 7, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected standup %+v", standup)
+	}
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	rule, err := parseRecurrenceRule("FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR,+2TU,WE;COUNT=5")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []byDay{{1, time.Monday}, {-1, time.Friday}, {2, time.Tuesday}, {0, time.Wednesday}}
+	if rule.Freq != "MONTHLY" || rule.Interval != 2 || rule.Count != 5 || len(rule.ByDay) != len(want) {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+	for i := range want {
+		if rule.ByDay[i] != want[i] {
+			t.Errorf("BYDAY %d: got %+v, want %+v", i, rule.ByDay[i], want[i])
+		}
+	}
+
+	for _, in := range []string{"FREQ=HOURLY", "FREQ=WEEKLY;INTERVAL=0", "FREQ=MONTHLY;BYDAY=1XX", "FREQ=MONTHLY;BYDAY=0MO"} {
+		if _, err := parseRecurrenceRule(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestOccurrencesAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		at    time.Time
+		want  bool
+	}{
+		{
+			name:  "weekly on chosen days",
+			event: "DTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n",
+			at:    local(2021, 6, 16, 9, 5),
+			want:  true,
+		},
+		{
+			name:  "weekly on other days",
+			event: "DTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n",
+			at:    local(2021, 6, 15, 9, 5),
+			want:  false,
+		},
+		{
+			name:  "first Monday of the month",
+			event: "DTSTART:20210607T100000\r\nDTEND:20210607T110000\r\nRRULE:FREQ=MONTHLY;BYDAY=1MO\r\n",
+			at:    local(2021, 7, 5, 10, 30),
+			want:  true,
+		},
+		{
+			name:  "not the second Monday of the month",
+			event: "DTSTART:20210607T100000\r\nDTEND:20210607T110000\r\nRRULE:FREQ=MONTHLY;BYDAY=1MO\r\n",
+			at:    local(2021, 7, 12, 10, 30),
+			want:  false,
+		},
+		{
+			name:  "not on DTSTART's day of the month",
+			event: "DTSTART:20210607T100000\r\nDTEND:20210607T110000\r\nRRULE:FREQ=MONTHLY;BYDAY=1MO\r\n",
+			at:    local(2021, 7, 7, 10, 30),
+			want:  false,
+		},
+		{
+			name:  "last Friday of the month",
+			event: "DTSTART:20210625T160000\r\nDTEND:20210625T170000\r\nRRULE:FREQ=MONTHLY;BYDAY=-1FR\r\n",
+			at:    local(2021, 7, 30, 16, 30),
+			want:  true,
+		},
+		{
+			name:  "not the second to last Friday",
+			event: "DTSTART:20210625T160000\r\nDTEND:20210625T170000\r\nRRULE:FREQ=MONTHLY;BYDAY=-1FR\r\n",
+			at:    local(2021, 7, 23, 16, 30),
+			want:  false,
+		},
+		{
+			name:  "monthly on DTSTART's day",
+			event: "DTSTART:20210131T100000\r\nDTEND:20210131T110000\r\nRRULE:FREQ=MONTHLY\r\n",
+			at:    local(2021, 3, 31, 10, 30),
+			want:  true,
+		},
+		{
+			name:  "excluded instance",
+			event: "DTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=DAILY\r\nEXDATE:20210608T090000,20210609T090000\r\n",
+			at:    local(2021, 6, 9, 9, 5),
+			want:  false,
+		},
+		{
+			name:  "instance after the excluded ones",
+			event: "DTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=DAILY\r\nEXDATE:20210608T090000,20210609T090000\r\n",
+			at:    local(2021, 6, 10, 9, 5),
+			want:  true,
+		},
+		{
+			name:  "after COUNT instances",
+			event: "DTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=DAILY;COUNT=3\r\n",
+			at:    local(2021, 6, 10, 9, 5),
+			want:  false,
+		},
+		{
+			name:  "after UNTIL",
+			event: "DTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=WEEKLY;UNTIL=20210615T000000\r\n",
+			at:    local(2021, 6, 21, 9, 5),
+			want:  false,
+		},
+		{
+			name:  "during a multi-day all-day event",
+			event: "DTSTART;VALUE=DATE:20210607\r\nDTEND;VALUE=DATE:20210609\r\n",
+			at:    local(2021, 6, 8, 23, 59),
+			want:  true,
+		},
+		{
+			name:  "the day after an all-day event",
+			event: "DTSTART;VALUE=DATE:20210607\r\nDTEND;VALUE=DATE:20210609\r\n",
+			at:    local(2021, 6, 9, 0, 0),
+			want:  false,
+		},
+		{
+			name:  "recurring all-day event",
+			event: "DTSTART;VALUE=DATE:20210604\r\nRRULE:FREQ=WEEKLY;BYDAY=FR\r\n",
+			at:    local(2021, 6, 11, 15, 0),
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		events, err := parseICalendar(strings.NewReader(calendar(
+			"BEGIN:VEVENT\r\nUID:e\r\nSUMMARY:" + tt.name + "\r\n" + tt.event + "END:VEVENT\r\n")))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if got := len(occurrencesAt(events, tt.at)) > 0; got != tt.want {
+			t.Errorf("%s: in progress at %s is %t, want %t", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestOccurrencesAtModifiedInstance(t *testing.T) {
+	events, err := parseICalendar(strings.NewReader(calendar(
+		"BEGIN:VEVENT\r\nUID:standup\r\nSUMMARY:Standup\r\nDTSTART:20210607T090000\r\nDTEND:20210607T091500\r\nRRULE:FREQ=DAILY\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nUID:standup\r\nSUMMARY:Late standup\r\nRECURRENCE-ID:20210608T090000\r\nDTSTART:20210608T110000\r\nDTEND:20210608T111500\r\nEND:VEVENT\r\n",
+	)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := occurrencesAt(events, local(2021, 6, 8, 9, 5)); len(got) != 0 {
+		t.Errorf("expected the moved instance to be skipped, got %+v", got)
+	}
+	got := occurrencesAt(events, local(2021, 6, 8, 11, 5))
+	if len(got) != 1 || got[0].Event.Summary != "Late standup" {
+		t.Errorf("expected the moved instance, got %+v", got)
+	}
+}
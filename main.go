@@ -1,24 +1,27 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/cli/safeexec"
 	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
 )
 
 func rootCmd() *cobra.Command {
-	return &cobra.Command{
+	verbose := false
+	cmd := &cobra.Command{
 		Use: "user-status",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return setupDebug(verbose)
+		},
 	}
+	cmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log API requests and responses to STDERR")
+
+	return cmd
 }
 
 type setOptions struct {
@@ -27,71 +30,53 @@ type setOptions struct {
 	Expiry  time.Duration
 	Emoji   string
 	OrgName string
+	// Vars are extra variables for the message template, as {{vars.name}}.
+	Vars map[string]string
+	// DryRun prints what would be sent instead of setting the status.
+	DryRun bool
+	// Template expands Message as a template. Only set turns it on, for
+	// messages the user typed; others may come from files, calendars or
+	// other people and are sent as they are.
+	Template bool
 }
 
-func prompt(em emojiManager, opts *setOptions) error {
+// expiryChoices are the expiries offered when prompting for a status.
+var expiryChoices = []string{"Never", "30m", "1h", "4h", "24h", "7d"}
+
+func prompt(em status.EmojiManager, opts *setOptions) error {
 	emojiChoices := []string{}
 	for _, e := range em.Emojis() {
-		emojiChoices = append(emojiChoices, fmt.Sprintf("%s %s %s", string(e.codepoint), e.names, e.desc))
+		emojiChoices = append(emojiChoices, fmt.Sprintf("%s %s %s", string(e.Codepoint), e.Names, e.Description))
 	}
-	qs := []*survey.Question{
-		{
-			Name:     "status",
-			Prompt:   &survey.Input{Message: "Status"},
-			Validate: survey.Required,
-		},
-		{
-			Name: "emoji",
-			Prompt: &survey.Select{
-				Message: "Emoji",
-				Options: emojiChoices,
-				Default: 147,
-			},
-		},
-		{
-			Name: "limited",
-			Prompt: &survey.Confirm{
-				Message: "Indicate limited availability?",
-			},
-		},
-		{
-			Name: "expiry",
-			Prompt: &survey.Select{
-				Message: "Clear status in",
-				Options: []string{
-					"Never",
-					"30m",
-					"1h",
-					"4h",
-					"24h",
-					"7d",
-				},
-			},
-		},
+
+	message, err := prompts.Input("Status", "", func(s string) error {
+		return validateMessage(em, s)
+	})
+	if err != nil {
+		return err
 	}
-	answers := struct {
-		Status  string
-		Emoji   int
-		Limited bool
-		Expiry  string
-	}{}
-	err := survey.Ask(qs, &answers)
+	emojiIndex, err := prompts.Select("Emoji", emojiChoices, 147)
 	if err != nil {
 		return err
 	}
-
-	if answers.Expiry == "Never" {
-		answers.Expiry = "0s"
+	limited, err := prompts.Confirm("Indicate limited availability?", false)
+	if err != nil {
+		return err
+	}
+	expiryIndex, err := prompts.Select("Clear status in", expiryChoices, 0)
+	if err != nil {
+		return err
 	}
 
-	if answers.Expiry == "7d" {
-		answers.Expiry = "168h"
+	expiry := expiryChoices[expiryIndex]
+	if expiry == "Never" {
+		expiry = "0s"
 	}
 
-	opts.Expiry, _ = time.ParseDuration(answers.Expiry)
-	opts.Message = answers.Status
-	opts.Emoji = em.Emojis()[answers.Emoji].names[0]
-	opts.Limited = answers.Limited
+	opts.Expiry, _ = status.ParseDuration(expiry)
+	opts.Message = message
+	opts.Emoji = em.Emojis()[emojiIndex].Names[0]
+	opts.Limited = limited
 
 	return nil
 }
@@ -106,6 +91,7 @@ func setCmd() *cobra.Command {
 			if len(args) > 0 {
 				opts.Message = args[0]
 			}
+			opts.Template = true
 			return runSet(opts)
 		},
 	}
@@ -113,129 +99,221 @@ func setCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Limited, "limited", "l", false, "Indicate limited availability")
 	cmd.Flags().DurationVarP(&opts.Expiry, "expiry", "E", time.Duration(0), "Expire status after this duration")
 	cmd.Flags().StringVarP(&opts.OrgName, "org", "o", "", "Limit status visibility to an organization")
+	cmd.Flags().StringToStringVar(&opts.Vars, "var", nil, "Set a `name=value` for {{vars.name}} in the message")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Print the mutation that would be sent instead of sending it")
 
 	return cmd
 }
 
 func runSet(opts setOptions) error {
-	em := newEmojiManager()
+	em := status.NewEmojiManager()
 	if opts.Message == "" {
 		err := prompt(em, &opts)
 		if err != nil {
 			return err
 		}
 	}
-	// TODO org flag -- punted on this bc i have to resolve an org ID and it didn't feel worth it.
-	mutation := `mutation($emoji: String!, $message: String!, $limited: Boolean!, $expiry: DateTime) {
-		changeUserStatus(input: {emoji: $emoji, message: $message, limitedAvailability: $limited, expiresAt: $expiry}) {
-			status {
-				message
-				emoji
-			}
-		}
-	}`
 
-	limited := "false"
-	if opts.Limited {
-		limited = "true"
+	message, err := expandMessage(em, opts)
+	if err != nil {
+		return err
 	}
+	opts.Message = message
+
+	return applyStatus(em, opts)
+}
 
-	expiry := "null"
-	if opts.Expiry > time.Duration(0) {
-		expiry = time.Now().Add(opts.Expiry).Format("2006-01-02T15:04:05-0700")
+// applyStatus sets the status described by opts as it is, without prompting
+// for or expanding the message, then reports it to the user and webhooks.
+func applyStatus(em status.EmojiManager, opts setOptions) error {
+	var err error
+	setOpts := status.SetOptions{
+		Message: opts.Message,
+		Emoji:   opts.Emoji,
+		Limited: opts.Limited,
+		Expiry:  opts.Expiry,
+	}
+	if opts.OrgName != "" {
+		setOpts.OrganizationID, err = apiClient.OrganizationID(opts.OrgName)
+		if err != nil {
+			return err
+		}
 	}
 
-	emoji := fmt.Sprintf(":%s:", opts.Emoji)
+	if opts.DryRun {
+		return printDryRun(em, setOpts, opts.OrgName)
+	}
+
+	notifier := newWebhookNotifier()
 
-	cmdArgs := []string{
-		"api", "graphql",
-		"-f", fmt.Sprintf("query=%s", mutation),
-		"-f", fmt.Sprintf("message=%s", opts.Message),
-		"-f", fmt.Sprintf("emoji=%s", emoji),
-		"-F", fmt.Sprintf("limited=%s", limited),
-		"-F", fmt.Sprintf("expiry=%s", expiry),
+	var newStatus *status.Status
+	err = withUserScope(func() (err error) {
+		newStatus, err = apiClient.Set(setOpts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if opts.OrgName != "" {
+		newStatus.Organization = &status.Organization{Login: opts.OrgName}
 	}
 
-	out, stderr, err := gh(cmdArgs...)
+	invalidateViewerCache()
+
+	msg := fmt.Sprintf("✓ Status set to %s %s", newStatus.Emoji, opts.Message)
+	fmt.Println(strings.TrimSpace(em.ReplaceAll(msg)))
+
+	notifier.notify(newStatus)
+
+	return nil
+}
+
+// printDryRun prints the mutation and variables that setting a status with
+// opts would send, followed by the status as it would be shown.
+func printDryRun(em status.EmojiManager, opts status.SetOptions, orgName string) error {
+	now := time.Now()
+	mutation, variables := status.SetRequest(opts, now)
+	b, err := json.MarshalIndent(variables, "", "  ")
 	if err != nil {
-		if !strings.Contains(stderr.String(), "one of the following scopes: ['user']") {
-			return err
-		}
+		return err
+	}
+	fmt.Printf("%s\n\n%s\n\n", mutation, b)
 
-		fmt.Println("! Sorry, this extension requires the 'user' scope.")
-		answer := false
-		err = survey.AskOne(
-			&survey.Confirm{
-				Message: "Would you like to add the user scope now?",
-				Default: true,
-			}, &answer)
-		if err != nil {
-			return fmt.Errorf("could not prompt: %w", err)
-		}
-		if !answer {
-			return nil
+	preview := fmt.Sprintf("Would set status to %s %s", variables["emoji"], opts.Message)
+	if opts.Limited {
+		preview += " (availability is limited)"
+	}
+	if opts.Expiry > 0 {
+		preview += fmt.Sprintf(" [expires %s]", now.Add(opts.Expiry).Format("2006-01-02 15:04"))
+	}
+	if orgName != "" {
+		preview += fmt.Sprintf(" [visible to %s]", orgName)
+	}
+	fmt.Println(em.ReplaceAll(preview))
+
+	return nil
+}
+
+// expandMessage renders opts.Message as a template if opts.Template is set,
+// and makes sure GitHub will accept the result. A dry run reports a message
+// that's too long instead of offering to shorten it.
+func expandMessage(em status.EmojiManager, opts setOptions) (string, error) {
+	message := opts.Message
+	if opts.Template {
+		vars := map[string]string{}
+		if c, err := loadConfig(); err == nil {
+			for k, v := range c.Vars {
+				vars[k] = v
+			}
 		}
-		if err = ghWithInput("auth", "refresh", "-s", "user"); err != nil {
-			return err
+		for k, v := range opts.Vars {
+			vars[k] = v
 		}
-		out, _, err = gh(cmdArgs...)
+
+		var err error
+		message, err = renderMessage(opts.Message, messageContext{
+			Now:    time.Now(),
+			Expiry: opts.Expiry,
+			Vars:   vars,
+		})
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 
-	type response struct {
-		Data struct {
-			ChangeUserStatus struct {
-				Status status
-			}
-		}
+	if opts.DryRun {
+		return message, validateMessage(em, message)
 	}
-	var resp response
-	err = json.Unmarshal(out.Bytes(), &resp)
-	if err != nil {
-		return fmt.Errorf("failed to deserialize JSON: %w", err)
+	return fitMessage(em, message)
+}
+
+func clearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "clear your GitHub status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runClear()
+		},
 	}
+}
 
-	if resp.Data.ChangeUserStatus.Status.Emoji != emoji {
-		return errors.New("failed to set status. Perhaps try another emoji")
+func runClear() error {
+	notifier := newWebhookNotifier()
+
+	err := withUserScope(apiClient.Clear)
+	if err != nil {
+		return err
 	}
 
-	msg := fmt.Sprintf("✓ Status set to %s %s", emoji, opts.Message)
-	fmt.Println(em.ReplaceAll(msg))
+	invalidateViewerCache()
+
+	fmt.Println("✓ Status cleared")
+
+	notifier.notify(nil)
 
 	return nil
 }
 
+// restoreStatus sets the status back to prev, which was read earlier by
+// apiStatus, keeping its organization and whatever remains of its expiry. The
+// message is set exactly as it was, even if it's only an emoji.
+func restoreStatus(prev *status.Status) error {
+	if prev == nil || (prev.Message == "" && prev.Emoji == "") {
+		return runClear()
+	}
+
+	opts := setOptions{
+		Message: prev.Message,
+		Emoji:   strings.Trim(prev.Emoji, ":"),
+		Limited: prev.IndicatesLimitedAvailability,
+	}
+	if prev.Organization != nil {
+		opts.OrgName = prev.Organization.Login
+	}
+	if prev.ExpiresAt != nil {
+		opts.Expiry = time.Until(*prev.ExpiresAt)
+		if opts.Expiry <= 0 {
+			return runClear()
+		}
+	}
+
+	return applyStatus(status.NewEmojiManager(), opts)
+}
+
 type getOptions struct {
-	Login string
+	Login    string
+	CacheTTL time.Duration
+	NoCache  bool
 }
 
 func getCmd() *cobra.Command {
-	return &cobra.Command{
+	opts := getOptions{}
+	cmd := &cobra.Command{
 		Use:   "get [<username>]",
 		Short: "get a GitHub user's status or your own",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts := getOptions{}
 			if len(args) > 0 {
 				opts.Login = args[0]
 			}
 			return runGet(opts)
 		},
 	}
-}
+	cmd.Flags().DurationVar(&opts.CacheTTL, "cache-ttl", time.Minute, "Use a cached status if it is younger than this")
+	cmd.Flags().BoolVar(&opts.NoCache, "no-cache", false, "Always fetch the status from GitHub")
 
-type status struct {
-	IndicatesLimitedAvailability bool
-	Message                      string
-	Emoji                        string
+	return cmd
 }
 
 func runGet(opts getOptions) error {
-	em := newEmojiManager()
+	em := status.NewEmojiManager()
 
-	s, err := apiStatus(opts.Login)
+	ttl := opts.CacheTTL
+	if opts.NoCache {
+		ttl = 0
+	}
+	s, err := cachedAPIStatus(opts.Login, ttl)
 	if err != nil {
 		return err
 	}
@@ -251,86 +329,64 @@ func runGet(opts getOptions) error {
 	return nil
 }
 
-func apiStatus(login string) (*status, error) {
-	key := "user"
-	query := fmt.Sprintf(
-		`query { user(login:"%s") { status { indicatesLimitedAvailability message emoji }}}`,
-		login)
-	if login == "" {
-		key = "viewer"
-		query = `query {viewer { status { indicatesLimitedAvailability message emoji }}}`
-	}
-
-	args := []string{"api", "graphql", "-f", fmt.Sprintf("query=%s", query)}
-	sout, _, err := gh(args...)
+// apiStatus fetches login's status, or the viewer's when login is empty, and
+// updates the cache with it.
+func apiStatus(login string) (*status.Status, error) {
+	ms, err := fetchStatus(login)
 	if err != nil {
 		return nil, err
 	}
+	return ms.Status, nil
+}
 
-	resp := map[string]map[string]map[string]status{}
-
-	err = json.Unmarshal(sout.Bytes(), &resp)
+// fetchStatus is apiStatus, but also returns the login the status belongs to,
+// which is useful when looking up the viewer.
+func fetchStatus(login string) (*status.MemberStatus, error) {
+	var ms *status.MemberStatus
+	err := withUserScope(func() (err error) {
+		ms, err = apiClient.Get(login)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to deserialize JSON: %w", err)
+		return nil, err
 	}
 
-	s, ok := resp["data"][key]["status"]
-	if !ok {
-		return nil, errors.New("failed to deserialize JSON")
-	}
+	// Failing to cache shouldn't fail the lookup.
+	_ = writeStatusCache(login, ms.Login, ms.Status)
 
-	return &s, nil
+	return ms, nil
 }
 
-func main() {
+// newRootCmd returns the root command with every subcommand added.
+func newRootCmd() *cobra.Command {
 	rc := rootCmd()
 	rc.AddCommand(setCmd())
 	rc.AddCommand(getCmd())
+	rc.AddCommand(clearCmd())
+	rc.AddCommand(watchCmd())
+	rc.AddCommand(scheduleCmd())
+	rc.AddCommand(syncCalendarCmd())
+	rc.AddCommand(focusCmd())
+	rc.AddCommand(autoAwayCmd())
+	rc.AddCommand(gitHookCmd())
+	rc.AddCommand(promptSegmentCmd())
+	rc.AddCommand(serveCmd())
+	rc.AddCommand(metricsCmd())
+	rc.AddCommand(reportCmd())
+	rc.AddCommand(syncCmd())
+	rc.AddCommand(followFileCmd())
+	rc.AddCommand(dashboardCmd())
+	rc.AddCommand(editCmd())
+	rc.AddCommand(webhookRetryCmd())
+
+	return rc
+}
 
+func main() {
+	rc := newRootCmd()
 	if err := rc.Execute(); err != nil {
 		// TODO not bothering as long as cobra is also printing error
 		//fmt.Println(err)
 		os.Exit(1)
 	}
 }
-
-// gh shells out to gh, returning STDOUT/STDERR and any error
-func gh(args ...string) (sout, eout bytes.Buffer, err error) {
-	ghBin, err := safeexec.LookPath("gh")
-	if err != nil {
-		err = fmt.Errorf("could not find gh. Is it installed? error: %w", err)
-		return
-	}
-
-	cmd := exec.Command(ghBin, args...)
-	cmd.Stderr = &eout
-	cmd.Stdout = &sout
-
-	err = cmd.Run()
-	if err != nil {
-		err = fmt.Errorf("failed to run gh. error: %w, stderr: %s", err, eout.String())
-		return
-	}
-
-	return
-}
-
-// gh shells out to gh, connecting IO handles for user input
-func ghWithInput(args ...string) error {
-	ghBin, err := safeexec.LookPath("gh")
-	if err != nil {
-		return fmt.Errorf("could not find gh. Is it installed? error: %w", err)
-	}
-
-	cmd := exec.Command(ghBin, args...)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("failed to run gh. error: %w", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setenv sets an environment variable for the rest of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	prev, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// setupTest points the config and cache directories at empty temporary ones
+// and installs a fake gh expecting the named fixtures.
+func setupTest(t *testing.T, fixtures ...string) *fakeGH {
+	t.Helper()
+	setenv(t, "GH_USER_STATUS_CONFIG_DIR", t.TempDir())
+	setenv(t, "GH_USER_STATUS_CACHE_DIR", t.TempDir())
+	setenv(t, "GH_HOST", "")
+	return newFakeGH(t, fixtures...)
+}
+
+// runCommand runs gh user-status with args and no terminal, returning what it
+// printed to STDOUT.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevStdin, prevStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, w
+	defer func() {
+		os.Stdin, os.Stdout = prevStdin, prevStdout
+	}()
+
+	out := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		out <- string(b)
+	}()
+
+	rc := newRootCmd()
+	rc.SetArgs(args)
+	rc.SilenceErrors = true
+	rc.SilenceUsage = true
+	err = rc.Execute()
+
+	w.Close()
+	return <-out, err
+}
+
+func TestSet(t *testing.T) {
+	setupTest(t, "set_pizza")
+
+	out, err := runCommand(t, "set", "-e", "pizza", "lunch")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🍕 lunch\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetLimitedWithExpiry(t *testing.T) {
+	setupTest(t, "set_limited_expiry")
+
+	out, err := runCommand(t, "set", "--limited", "--expiry", "1h", "heads down")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 💭 heads down\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetEmojiMismatch(t *testing.T) {
+	setupTest(t, "set_emoji_mismatch")
+
+	out, err := runCommand(t, "set", "-e", "not_an_emoji", "lunch")
+	if err == nil || !strings.Contains(err.Error(), "Perhaps try another emoji") {
+		t.Fatalf("expected emoji mismatch error, got %v", err)
+	}
+	if out != "" {
+		t.Errorf("expected no output, got %q", out)
+	}
+}
+
+func TestSetScopeDeclinedWithoutTerminal(t *testing.T) {
+	f := setupTest(t, "set_insufficient_scopes")
+
+	_, err := runCommand(t, "set", "lunch")
+	if !errors.Is(err, errScopeDeclined) {
+		t.Fatalf("expected errScopeDeclined, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "gh auth refresh -s user") {
+		t.Errorf("expected a hint to refresh the token, got %q", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected only the failed request, got %q", f.calls)
+	}
+}
+
+func TestClear(t *testing.T) {
+	setupTest(t, "clear")
+
+	out, err := runCommand(t, "clear")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status cleared\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestGetViewer(t *testing.T) {
+	setupTest(t, "get_viewer")
+
+	out, err := runCommand(t, "get")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "🌴 on vacation (availability is limited)\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	setupTest(t, "get_user")
+
+	out, err := runCommand(t, "get", "vilmibm")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "🍕 lunch \n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestGetUsesCache(t *testing.T) {
+	f := setupTest(t, "get_user")
+
+	for i := 0; i < 2; i++ {
+		out, err := runCommand(t, "get", "vilmibm")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if want := "🍕 lunch \n"; out != want {
+			t.Errorf("got %q, want %q", out, want)
+		}
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected the second get to be cached, got %d calls", len(f.calls))
+	}
+}
+
+func TestSetOrg(t *testing.T) {
+	setupTest(t, "organization_cli", "set_org")
+
+	out, err := runCommand(t, "set", "--org", "cli", "-e", "eyes", "reviewing PRs")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 👀 reviewing PRs\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetOrgMissing(t *testing.T) {
+	f := setupTest(t, "organization_missing")
+
+	_, err := runCommand(t, "set", "--org", "nope", "reviewing PRs")
+	if err == nil || !strings.Contains(err.Error(), "Could not resolve to an Organization") {
+		t.Errorf("expected the organization not to be found, got %v", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected no status to be set, got %q", f.calls)
+	}
+}
+
+func TestSetDryRun(t *testing.T) {
+	f := setupTest(t, "organization_cli")
+
+	out, err := runCommand(t, "set", "--dry-run", "--org", "cli", "-e", "eyes", "-l", "-E", "1h", "reviewing PRs")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected only the organization to be looked up, got %q", f.calls)
+	}
+	for _, want := range []string{
+		"changeUserStatus(input: {emoji: $emoji",
+		`"emoji": ":eyes:"`,
+		`"limited": true`,
+		`"message": "reviewing PRs"`,
+		`"organizationId": "O_kgDOAbc123"`,
+		"Would set status to 👀 reviewing PRs (availability is limited) [expires ",
+		"[visible to cli]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestWatchRejectsShortInterval(t *testing.T) {
+	f := setupTest(t)
+
+	for _, interval := range []string{"0", "-1m", "1s"} {
+		_, err := runCommand(t, "watch", "--interval", interval, "mislav")
+		if err == nil || !strings.Contains(err.Error(), "at least 10s") {
+			t.Errorf("%s: expected the interval to be rejected, got %v", interval, err)
+		}
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("expected no requests, got %q", f.calls)
+	}
+}
+
+func TestRestoreStatusKeepsOrganization(t *testing.T) {
+	setupTest(t, "get_viewer_org", "organization_cli", "set_org")
+
+	prev, err := apiStatus("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := restoreStatus(prev); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestRestoreStatusEmojiOnly(t *testing.T) {
+	setupTest(t, "get_viewer_emoji_only", "set_emoji_only")
+
+	prev, err := apiStatus("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := restoreStatus(prev); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestSetInvalidatesOwnLoginWithoutViewerEntry(t *testing.T) {
+	// Looked up by login only, so which user is the viewer isn't known yet.
+	f := setupTest(t, "get_user", "set_pizza", "get_user")
+
+	for _, args := range [][]string{
+		{"get", "vilmibm"},
+		{"set", "-e", "pizza", "lunch"},
+		{"get", "vilmibm"},
+	} {
+		if _, err := runCommand(t, args...); err != nil {
+			t.Fatalf("%s: unexpected error: %s", args, err)
+		}
+	}
+	if len(f.calls) != 3 {
+		t.Errorf("expected the status to be fetched again after set, got %d calls", len(f.calls))
+	}
+}
+
+func TestSetInvalidatesOnlyViewer(t *testing.T) {
+	setupTest(t, "get_viewer", "get_user", "set_pizza")
+
+	for _, args := range [][]string{
+		{"get"},
+		{"get", "vilmibm"},
+		{"set", "-e", "pizza", "lunch"},
+	} {
+		if _, err := runCommand(t, args...); err != nil {
+			t.Fatalf("%s: unexpected error: %s", args, err)
+		}
+	}
+
+	for login, cached := range map[string]bool{"": false, "monalisa": false, "vilmibm": true} {
+		c, err := readStatusCache(login)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (c != nil) != cached {
+			t.Errorf("%q: expected cached to be %t, got %+v", login, cached, c)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// maxMessageLength is the longest status message GitHub accepts, in
+// characters as a reader would count them.
+const maxMessageLength = 80
+
+const zeroWidthJoiner = 0x200D
+
+// isGraphemeExtender reports whether r belongs to the character before it:
+// combining marks, variation selectors, skin tones and emoji tags.
+func isGraphemeExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) ||
+		r == 0xFE0E || r == 0xFE0F ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) ||
+		(r >= 0xE0020 && r <= 0xE007F)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// graphemes splits s into user-perceived characters. It covers what turns up
+// in statuses -- combining accents, emoji with skin tones, ZWJ sequences,
+// flags and keycaps -- rather than every rule of Unicode text segmentation.
+func graphemes(s string) []string {
+	rs := []rune(s)
+	out := []string{}
+	for i := 0; i < len(rs); {
+		j := i + 1
+		if isRegionalIndicator(rs[i]) && j < len(rs) && isRegionalIndicator(rs[j]) {
+			j++
+		}
+		for j < len(rs) {
+			if isGraphemeExtender(rs[j]) {
+				j++
+			} else if rs[j] == zeroWidthJoiner && j+1 < len(rs) {
+				j += 2
+			} else {
+				break
+			}
+		}
+		out = append(out, string(rs[i:j]))
+		i = j
+	}
+	return out
+}
+
+var shortcodeRE = regexp.MustCompile(`:[a-z0-9_+\-]+:`)
+
+// messageUnits splits a message into the characters GitHub counts towards its
+// limit. A known :shortcode: is displayed, and counted, as the single emoji it
+// expands to.
+func messageUnits(em status.EmojiManager, message string) []string {
+	units := []string{}
+	last := 0
+	for _, loc := range shortcodeRE.FindAllStringIndex(message, -1) {
+		if _, ok := em.Lookup(message[loc[0]:loc[1]]); !ok {
+			continue
+		}
+		units = append(units, graphemes(message[last:loc[0]])...)
+		units = append(units, message[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	return append(units, graphemes(message[last:])...)
+}
+
+// messageTooLongError is returned for messages over maxMessageLength.
+type messageTooLongError struct {
+	Length int
+}
+
+func (e messageTooLongError) Error() string {
+	return fmt.Sprintf("status message is %d characters long; GitHub allows at most %d", e.Length, maxMessageLength)
+}
+
+// validateMessage checks a message against GitHub's limits before any request
+// is made.
+func validateMessage(em status.EmojiManager, message string) error {
+	if strings.TrimSpace(message) == "" {
+		return errors.New("a status message is required")
+	}
+	if strings.ContainsAny(message, "\r\n") {
+		return errors.New("status messages can't contain line breaks")
+	}
+	if n := len(messageUnits(em, message)); n > maxMessageLength {
+		return messageTooLongError{Length: n}
+	}
+	return nil
+}
+
+// validateStatus checks an emoji shortcode and message before any request is
+// made, for commands that set statuses unattended and need to tell a status
+// GitHub will never accept from a request that failed.
+func validateStatus(em status.EmojiManager, emoji, message string) error {
+	if _, ok := em.Lookup(emoji); !ok {
+		return fmt.Errorf("unknown emoji %q", emoji)
+	}
+	return validateMessage(em, message)
+}
+
+// truncateMessage shortens a message to maxMessageLength characters, ending it
+// with an ellipsis.
+func truncateMessage(em status.EmojiManager, message string) string {
+	units := messageUnits(em, message)
+	if len(units) <= maxMessageLength {
+		return message
+	}
+	return strings.TrimRight(strings.Join(units[:maxMessageLength-1], ""), " ") + "…"
+}
+
+// fitMessage validates a message and, when it's too long and there's somebody
+// to ask, offers to truncate it or shorten it in their editor.
+func fitMessage(em status.EmojiManager, message string) (string, error) {
+	for {
+		err := validateMessage(em, message)
+		var tooLong messageTooLongError
+		if err == nil || !errors.As(err, &tooLong) || !stdinIsTerminal() {
+			return message, err
+		}
+
+		truncated := truncateMessage(em, message)
+		choice, err := prompts.Select(
+			fmt.Sprintf("Your status is %d characters long, but GitHub allows at most %d. What now?", tooLong.Length, maxMessageLength),
+			[]string{
+				fmt.Sprintf("Truncate it to %q", truncated),
+				"Shorten it in your editor",
+				"Cancel",
+			}, 0)
+		if err != nil {
+			return "", err
+		}
+		switch choice {
+		case 0:
+			return truncated, nil
+		case 1:
+			edited, err := openEditor("status.txt", message+"\n")
+			if err != nil {
+				return "", err
+			}
+			message = strings.Join(strings.Fields(edited), " ")
+		default:
+			return "", tooLong
+		}
+	}
+}
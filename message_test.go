@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func TestMessageUnits(t *testing.T) {
+	em := status.NewEmojiManager()
+	tests := map[string]int{
+		"lunch":                   5,
+		"caf\u00e9":               4,
+		"cafe\u0301":              4,
+		"\U0001F44D\U0001F3FD ok": 4,
+		"\U0001F469\u200D\U0001F469\u200D\U0001F467 family": 8,
+		"\U0001F1F3\U0001F1FF trip":                         6,
+		"1\uFE0F\u20E3 first":                               7,
+		":pizza: lunch":                                     7,
+		":not_an_emoji: lunch":                              20,
+		"ratio 1:2:3":                                       11,
+	}
+	for in, want := range tests {
+		if got := len(messageUnits(em, in)); got != want {
+			t.Errorf("%q: got %d characters, want %d", in, got, want)
+		}
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	em := status.NewEmojiManager()
+
+	if err := validateMessage(em, strings.Repeat("\U0001F44D\U0001F3FD", 80)); err != nil {
+		t.Errorf("expected 80 emoji to fit, got %s", err)
+	}
+	var tooLong messageTooLongError
+	if err := validateMessage(em, strings.Repeat(":pizza:", 81)); !errors.As(err, &tooLong) || tooLong.Length != 81 {
+		t.Errorf("expected 81 emoji to be too long, got %v", err)
+	}
+	if err := validateMessage(em, "two\nlines"); err == nil {
+		t.Error("expected line breaks to be rejected")
+	}
+	if err := validateMessage(em, "  "); err == nil {
+		t.Error("expected a blank message to be rejected")
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	em := status.NewEmojiManager()
+	msg := ":pizza: " + strings.Repeat("é", 100)
+
+	got := truncateMessage(em, msg)
+	if n := len(messageUnits(em, got)); n != maxMessageLength {
+		t.Errorf("expected %d characters, got %d", maxMessageLength, n)
+	}
+	if !strings.HasPrefix(got, ":pizza: ") || !strings.HasSuffix(got, "…") {
+		t.Errorf("unexpected truncation %q", got)
+	}
+}
+
+func TestSetTruncatesLongMessage(t *testing.T) {
+	setupTest(t, "set_truncated")
+	long := "reviewing " + strings.Repeat("x", 80)
+	truncated := truncateMessage(status.NewEmojiManager(), long)
+	scriptPrompts(t, scriptedAnswer{
+		"Your status is 90 characters long, but GitHub allows at most 80. What now?",
+		"Truncate it to \"" + truncated + "\"",
+	})
+
+	out, err := runCommand(t, "set", long)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 💭 " + truncated + "\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetShortensLongMessageInEditor(t *testing.T) {
+	setupTest(t, "set_thought_balloon")
+	setenv(t, "GH_EDITOR", "sed -i -e s/x*$//")
+	scriptPrompts(t, scriptedAnswer{
+		"Your status is 85 characters long, but GitHub allows at most 80. What now?",
+		"Shorten it in your editor",
+	})
+
+	out, err := runCommand(t, "set", "lunch"+strings.Repeat("x", 80))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 💭 lunch\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetDryRunReportsLongMessage(t *testing.T) {
+	f := setupTest(t)
+	scriptPrompts(t)
+
+	out, err := runCommand(t, "set", "--dry-run", "reviewing "+strings.Repeat("x", 80))
+	var tooLong messageTooLongError
+	if !errors.As(err, &tooLong) || tooLong.Length != 90 {
+		t.Errorf("expected the message to be reported as too long, got %v", err)
+	}
+	if out != "" || len(f.calls) != 0 {
+		t.Errorf("expected nothing to be printed or sent, got %q and %q", out, f.calls)
+	}
+}
+
+func TestPromptRejectsLongMessage(t *testing.T) {
+	f := setupTest(t)
+	scriptPrompts(t, scriptedAnswer{"Status", strings.Repeat("x", 81)})
+
+	_, err := runCommand(t, "set")
+	var tooLong messageTooLongError
+	if !errors.As(err, &tooLong) {
+		t.Errorf("expected the answer to be rejected, got %v", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("expected no requests, got %q", f.calls)
+	}
+}
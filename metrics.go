@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// apiMetrics counts GraphQL requests per operation for the /metrics endpoint.
+type apiMetrics struct {
+	mu  sync.Mutex
+	ops map[string]*operationMetrics
+}
+
+type operationMetrics struct {
+	Requests uint64
+	Errors   uint64
+	Seconds  float64
+}
+
+var apiStats = &apiMetrics{ops: map[string]*operationMetrics{}}
+
+func (m *apiMetrics) observe(operation string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	om, ok := m.ops[operation]
+	if !ok {
+		om = &operationMetrics{}
+		m.ops[operation] = om
+	}
+	om.Requests++
+	om.Seconds += d.Seconds()
+	if err != nil {
+		om.Errors++
+	}
+}
+
+func (m *apiMetrics) snapshot() map[string]operationMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]operationMetrics{}
+	for op, om := range m.ops {
+		out[op] = *om
+	}
+	return out
+}
+
+var operationRE = regexp.MustCompile(`\{\s*(?:\w+\s*:\s*)?(\w+)`)
+
+// operationName names a GraphQL request after its first top-level field,
+// e.g. changeUserStatus or viewer.
+func operationName(query string) string {
+	m := operationRE.FindStringSubmatch(query)
+	if m == nil {
+		return "unknown"
+	}
+	return m[1]
+}
+
+// writeMetrics writes team availability gauges for statuses, and the API
+// counters gathered so far, in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, statuses []status.MemberStatus, expiringWithin time.Duration, now time.Time) error {
+	total, withStatus, limited, expiring := 0, 0, 0, 0
+	for _, ms := range statuses {
+		total++
+		s := ms.Status
+		if s == nil || (s.Message == "" && s.Emoji == "") {
+			continue
+		}
+		withStatus++
+		if s.IndicatesLimitedAvailability {
+			limited++
+		}
+		if s.ExpiresAt != nil && s.ExpiresAt.After(now) && s.ExpiresAt.Sub(now) <= expiringWithin {
+			expiring++
+		}
+	}
+
+	gauge := func(name, help string, value int, labels string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s%s %d\n", name, help, name, name, labels, value)
+	}
+	gauge("gh_user_status_members", "Number of users whose status is tracked.", total, "")
+	gauge("gh_user_status_members_with_status", "Number of users with any status set.", withStatus, "")
+	gauge("gh_user_status_members_limited", "Number of users indicating limited availability.", limited, "")
+	gauge("gh_user_status_members_expiring", "Number of users whose status expires soon.", expiring,
+		fmt.Sprintf(`{within_hours="%g"}`, expiringWithin.Hours()))
+
+	ops := apiStats.snapshot()
+	names := []string{}
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP gh_user_status_api_requests_total GraphQL requests made, by operation.")
+	fmt.Fprintln(w, "# TYPE gh_user_status_api_requests_total counter")
+	for _, op := range names {
+		fmt.Fprintf(w, "gh_user_status_api_requests_total{operation=%q} %d\n", op, ops[op].Requests)
+	}
+	fmt.Fprintln(w, "# HELP gh_user_status_api_errors_total GraphQL requests that failed, by operation.")
+	fmt.Fprintln(w, "# TYPE gh_user_status_api_errors_total counter")
+	for _, op := range names {
+		fmt.Fprintf(w, "gh_user_status_api_errors_total{operation=%q} %d\n", op, ops[op].Errors)
+	}
+	fmt.Fprintln(w, "# HELP gh_user_status_api_request_duration_seconds Time spent on GraphQL requests, by operation.")
+	fmt.Fprintln(w, "# TYPE gh_user_status_api_request_duration_seconds summary")
+	for _, op := range names {
+		fmt.Fprintf(w, "gh_user_status_api_request_duration_seconds_sum{operation=%q} %g\n", op, ops[op].Seconds)
+		fmt.Fprintf(w, "gh_user_status_api_request_duration_seconds_count{operation=%q} %d\n", op, ops[op].Requests)
+	}
+
+	return nil
+}
+
+type metricsOptions struct {
+	Logins         []string
+	Team           string
+	ExpiringWithin time.Duration
+}
+
+func metricsCmd() *cobra.Command {
+	opts := metricsOptions{}
+	cmd := &cobra.Command{
+		Use:   "metrics [<username>...]",
+		Short: "print status metrics in Prometheus format",
+		Long: `Fetch the statuses of the given users, or of every member of a team, once and
+print availability metrics in the Prometheus text format, e.g. for the node
+exporter's textfile collector.
+
+For continuous scraping, "gh user-status serve" exposes the same metrics at
+/metrics.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Logins = args
+			if err := checkTargets(opts.Logins, opts.Team); err != nil {
+				return err
+			}
+			return runMetrics(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Team, "team", "t", "", "Report on every member of an <org>/<team>")
+	cmd.Flags().DurationVar(&opts.ExpiringWithin, "expiring-within", 4*time.Hour, "Count statuses expiring within this long")
+
+	return cmd
+}
+
+func runMetrics(opts metricsOptions) error {
+	statuses, _, err := fetchStatuses(opts.Logins, opts.Team)
+	if err != nil {
+		return err
+	}
+	return writeMetrics(os.Stdout, statuses, opts.ExpiringWithin, time.Now())
+}
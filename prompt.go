@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+type promptSegmentOptions struct {
+	MaxLength int
+	TTL       time.Duration
+	Refresh   bool
+}
+
+func promptSegmentCmd() *cobra.Command {
+	opts := promptSegmentOptions{}
+	cmd := &cobra.Command{
+		Use:   "prompt-segment",
+		Short: "print your status compactly for a shell prompt",
+		Long: `Print your status emoji and a shortened message, for use in PS1, starship,
+powerlevel10k and the like.
+
+This never waits on the network: it prints whatever status is cached and, if
+that is older than --ttl, refreshes the cache in the background for the next
+prompt. Nothing is printed until the first refresh has finished, or when you
+have no status.`,
+		Example: `  # bash
+  PS1='$(gh user-status prompt-segment) \w \$ '
+
+  # starship.toml
+  [custom.user_status]
+  command = "gh user-status prompt-segment"
+  when = true`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPromptSegment(opts)
+		},
+	}
+	cmd.Flags().IntVarP(&opts.MaxLength, "max-length", "m", 20, "Truncate the message to this many characters")
+	cmd.Flags().DurationVar(&opts.TTL, "ttl", 5*time.Minute, "Refresh the cached status once it is this old")
+	cmd.Flags().BoolVar(&opts.Refresh, "refresh", false, "Fetch the status and update the cache")
+	_ = cmd.Flags().MarkHidden("refresh")
+
+	return cmd
+}
+
+// promptRefreshBackoff is how long to wait for one background refresh before
+// starting another, so that a burst of prompts spawns only one.
+const promptRefreshBackoff = 30 * time.Second
+
+func runPromptSegment(opts promptSegmentOptions) error {
+	if opts.Refresh {
+		// apiStatus caches what it fetches.
+		_, err := apiStatus("")
+		return err
+	}
+
+	// A prompt must stay fast and quiet, so errors only ever mean printing
+	// less.
+	cached, _ := readStatusCache("")
+	if cached == nil || time.Since(cached.FetchedAt) > opts.TTL {
+		startPromptRefresh()
+	}
+	if cached == nil || cached.Status == nil || cached.Status.Message == "" {
+		return nil
+	}
+	s := cached.Status
+	if s.ExpiresAt != nil && s.ExpiresAt.Before(time.Now()) {
+		return nil
+	}
+
+	em := status.NewEmojiManager()
+	fmt.Print(em.ReplaceAll(s.Emoji), " ", truncate(s.Message, opts.MaxLength))
+
+	return nil
+}
+
+// startPromptRefresh runs prompt-segment --refresh in the background without
+// waiting for it, unless another refresh started recently.
+func startPromptRefresh() {
+	dir, err := cacheDir()
+	if err != nil {
+		return
+	}
+	lock := filepath.Join(dir, "prompt-refresh.lock")
+	if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) < promptRefreshBackoff {
+		return
+	}
+	if f, err := os.Create(lock); err == nil {
+		f.Close()
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(self, "prompt-segment", "--refresh")
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	_ = cmd.Process.Release()
+}
+
+// truncate shortens s to at most max characters, ending with an ellipsis if
+// anything was cut.
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if max <= 0 || len(r) <= max {
+		return s
+	}
+	if max == 1 {
+		return "…"
+	}
+	return string(r[:max-1]) + "…"
+}
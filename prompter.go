@@ -0,0 +1,70 @@
+package main
+
+import "github.com/AlecAivazis/survey/v2"
+
+// prompter asks the user questions. It's a variable so that tests can script
+// the answers.
+type prompter interface {
+	// Input asks for a line of text, re-asking until validate, if not nil,
+	// accepts it.
+	Input(message, defaultValue string, validate func(string) error) (string, error)
+	// Select asks for one of options, returning its index.
+	Select(message string, options []string, defaultIndex int) (int, error)
+	Confirm(message string, defaultValue bool) (bool, error)
+	// MultiSelect asks for any number of options, returning their indexes.
+	MultiSelect(message string, options []string, defaults []int) ([]int, error)
+}
+
+var prompts prompter = surveyPrompter{}
+
+// surveyPrompter asks questions on the terminal using survey.
+type surveyPrompter struct{}
+
+func (surveyPrompter) Input(message, defaultValue string, validate func(string) error) (string, error) {
+	answer := ""
+	opts := []survey.AskOpt{}
+	if validate != nil {
+		opts = append(opts, survey.WithValidator(func(v interface{}) error {
+			s, _ := v.(string)
+			return validate(s)
+		}))
+	}
+	err := survey.AskOne(&survey.Input{
+		Message: message,
+		Default: defaultValue,
+	}, &answer, opts...)
+	return answer, err
+}
+
+func (surveyPrompter) Select(message string, options []string, defaultIndex int) (int, error) {
+	answer := 0
+	err := survey.AskOne(&survey.Select{
+		Message: message,
+		Options: options,
+		Default: defaultIndex,
+	}, &answer)
+	return answer, err
+}
+
+func (surveyPrompter) Confirm(message string, defaultValue bool) (bool, error) {
+	answer := false
+	err := survey.AskOne(&survey.Confirm{
+		Message: message,
+		Default: defaultValue,
+	}, &answer)
+	return answer, err
+}
+
+func (surveyPrompter) MultiSelect(message string, options []string, defaults []int) ([]int, error) {
+	defaultOptions := []string{}
+	for _, i := range defaults {
+		defaultOptions = append(defaultOptions, options[i])
+	}
+	answer := []int{}
+	err := survey.AskOne(&survey.MultiSelect{
+		Message: message,
+		Options: options,
+		Default: defaultOptions,
+	}, &answer)
+	return answer, err
+}
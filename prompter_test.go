@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptedAnswer answers the prompt with the given message. Answer is the
+// text for Input, the chosen option for Select, a bool for Confirm and the
+// chosen options for MultiSelect.
+type scriptedAnswer struct {
+	Message string
+	Answer  interface{}
+}
+
+// fakePrompter answers prompts from a script, failing the test if they are
+// asked in a different order or the script runs out.
+type fakePrompter struct {
+	t      *testing.T
+	script []scriptedAnswer
+}
+
+// scriptPrompts installs a fakePrompter answering with script, pretends that
+// stdin is a terminal, and checks that every answer was used when the test
+// finishes.
+func scriptPrompts(t *testing.T, script ...scriptedAnswer) *fakePrompter {
+	t.Helper()
+	p := &fakePrompter{t: t, script: script}
+
+	prevPrompts, prevTerminal := prompts, stdinIsTerminal
+	prompts = p
+	stdinIsTerminal = func() bool { return true }
+	t.Cleanup(func() {
+		prompts, stdinIsTerminal = prevPrompts, prevTerminal
+		if len(p.script) > 0 {
+			t.Errorf("prompts were never asked: %v", p.script)
+		}
+	})
+
+	return p
+}
+
+func (p *fakePrompter) next(message string) (interface{}, error) {
+	if len(p.script) == 0 {
+		p.t.Errorf("unexpected prompt %q", message)
+		return nil, errors.New("unexpected prompt")
+	}
+	a := p.script[0]
+	p.script = p.script[1:]
+	if a.Message != message {
+		p.t.Errorf("expected prompt %q, got %q", a.Message, message)
+		return nil, errors.New("unexpected prompt")
+	}
+	return a.Answer, nil
+}
+
+func indexOf(options []string, option string) (int, error) {
+	for i, o := range options {
+		if o == option {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not an option", option)
+}
+
+func (p *fakePrompter) Input(message, defaultValue string, validate func(string) error) (string, error) {
+	a, err := p.next(message)
+	if err != nil {
+		return "", err
+	}
+	s := a.(string)
+	if validate != nil {
+		// survey would ask again; there's no second answer to give.
+		if err := validate(s); err != nil {
+			return "", err
+		}
+	}
+	return s, nil
+}
+
+func (p *fakePrompter) Select(message string, options []string, defaultIndex int) (int, error) {
+	a, err := p.next(message)
+	if err != nil {
+		return 0, err
+	}
+	i, err := indexOf(options, a.(string))
+	if err != nil {
+		p.t.Error(err)
+	}
+	return i, err
+}
+
+func (p *fakePrompter) Confirm(message string, defaultValue bool) (bool, error) {
+	a, err := p.next(message)
+	if err != nil {
+		return false, err
+	}
+	return a.(bool), nil
+}
+
+func (p *fakePrompter) MultiSelect(message string, options []string, defaults []int) ([]int, error) {
+	a, err := p.next(message)
+	if err != nil {
+		return nil, err
+	}
+	chosen := []int{}
+	for _, o := range a.([]string) {
+		i, err := indexOf(options, o)
+		if err != nil {
+			p.t.Error(err)
+			return nil, err
+		}
+		chosen = append(chosen, i)
+	}
+	return chosen, nil
+}
+
+func TestSetPrompts(t *testing.T) {
+	setupTest(t, "set_limited_expiry")
+	scriptPrompts(t,
+		scriptedAnswer{"Status", "heads down"},
+		scriptedAnswer{"Emoji", "💭 [thought_balloon] thought balloon"},
+		scriptedAnswer{"Indicate limited availability?", true},
+		scriptedAnswer{"Clear status in", "1h"},
+	)
+
+	out, err := runCommand(t, "set")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 💭 heads down\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetPromptsNeverExpire(t *testing.T) {
+	setupTest(t, "set_pizza")
+	scriptPrompts(t,
+		scriptedAnswer{"Status", "lunch"},
+		scriptedAnswer{"Emoji", "🍕 [pizza] pizza"},
+		scriptedAnswer{"Indicate limited availability?", false},
+		scriptedAnswer{"Clear status in", "Never"},
+	)
+
+	out, err := runCommand(t, "set")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🍕 lunch\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetScopeRefused(t *testing.T) {
+	f := setupTest(t, "set_insufficient_scopes")
+	scriptPrompts(t,
+		scriptedAnswer{"Would you like to add the user scope now?", false},
+	)
+
+	out, err := runCommand(t, "set", "lunch")
+	if !errors.Is(err, errScopeDeclined) {
+		t.Fatalf("expected errScopeDeclined, got %v", err)
+	}
+	if !strings.Contains(out, "requires the 'user' scope") {
+		t.Errorf("expected an explanation, got %q", out)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected no call to gh auth refresh, got %q", f.calls)
+	}
+}
+
+func TestSetScopeAdded(t *testing.T) {
+	f := setupTest(t, "set_insufficient_scopes", "set_thought_balloon")
+	scriptPrompts(t,
+		scriptedAnswer{"Would you like to add the user scope now?", true},
+	)
+
+	out, err := runCommand(t, "set", "lunch")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasSuffix(out, "✓ Status set to 💭 lunch\n") {
+		t.Errorf("got %q", out)
+	}
+	refresh := []string{"auth", "refresh", "-s", "user"}
+	if len(f.calls) != 3 || !reflect.DeepEqual(f.calls[1], refresh) {
+		t.Errorf("expected the token to be refreshed between requests, got %q", f.calls)
+	}
+}
+
+func TestScheduleRemoveChooses(t *testing.T) {
+	setupTest(t)
+	for _, args := range [][]string{
+		{"schedule", "add", "--at", "2030-01-01 09:00", "-e", "pizza", "lunch"},
+		{"schedule", "add", "--at", "2030-01-02 09:00", "standup"},
+	} {
+		if _, err := runCommand(t, args...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	scriptPrompts(t,
+		scriptedAnswer{"Remove which scheduled statuses?", []string{"#1 🍕 lunch from Tue Jan 1 09:00"}},
+	)
+
+	out, err := runCommand(t, "schedule", "remove")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Removed 1 scheduled status(es)\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	s, err := loadSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Entries) != 1 || s.Entries[0].Message != "standup" {
+		t.Errorf("expected only the standup to remain, got %+v", s.Entries)
+	}
+}
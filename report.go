@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// Availability groups, in the order reports list them.
+const (
+	groupOut       = "out"
+	groupLimited   = "limited"
+	groupAvailable = "available"
+)
+
+var reportGroups = []string{groupOut, groupLimited, groupAvailable}
+
+var (
+	outKeywords = append(append([]string{}, timeOffKeywords...), sickKeywords...)
+	outEmoji    = []string{":palm_tree:", ":airplane:", ":beach_umbrella:", ":desert_island:", ":face_with_thermometer:", ":mask:"}
+)
+
+// availabilityGroup sorts a status into out, limited or available. Statuses
+// that look like time off count as out whether or not they are marked
+// limited.
+func availabilityGroup(s *status.Status) string {
+	if s == nil {
+		return groupAvailable
+	}
+	msg := strings.ToLower(s.Message)
+	for _, kw := range outKeywords {
+		if strings.Contains(msg, kw) {
+			return groupOut
+		}
+	}
+	for _, e := range outEmoji {
+		if s.Emoji == e {
+			return groupOut
+		}
+	}
+	if s.IndicatesLimitedAvailability {
+		return groupLimited
+	}
+	return groupAvailable
+}
+
+type reportOptions struct {
+	Logins     []string
+	Team       string
+	Format     string
+	Since      string
+	Shortcodes bool
+}
+
+func reportCmd() *cobra.Command {
+	opts := reportOptions{}
+	cmd := &cobra.Command{
+		Use:   "report [<username>...]",
+		Short: "print a table of statuses for notes",
+		Long: `Print the statuses of the given users, or of every member of a team, as a
+Markdown, CSV or HTML table grouped into those who are out, those with limited
+availability and those who are available.
+
+With --since, changes recorded by "gh user-status watch" in that period are
+listed as well.`,
+		Example: `  gh user-status report --team cli/maintainers
+  gh user-status report --team cli/maintainers --format csv --since 7d > availability.csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Logins = args
+			if err := checkTargets(opts.Logins, opts.Team); err != nil {
+				return err
+			}
+			switch opts.Format {
+			case "markdown", "csv", "html":
+			default:
+				return fmt.Errorf("unknown format %q; use markdown, csv or html", opts.Format)
+			}
+			return runReport(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Team, "team", "t", "", "Report on every member of an <org>/<team>")
+	cmd.Flags().StringVarP(&opts.Format, "format", "f", "markdown", "Output format: markdown, csv or html")
+	cmd.Flags().StringVarP(&opts.Since, "since", "s", "", "Include changes recorded in this period, e.g. 7d")
+	cmd.Flags().BoolVar(&opts.Shortcodes, "shortcodes", false, "Leave emoji as :shortcodes:")
+
+	return cmd
+}
+
+// reportRow is one member's line in a report.
+type reportRow struct {
+	Group   string
+	Login   string
+	Emoji   string
+	Message string
+	Limited bool
+	Expiry  *time.Time
+}
+
+type reportChange struct {
+	Time time.Time
+	reportRow
+}
+
+func runReport(opts reportOptions) error {
+	var since time.Time
+	if opts.Since != "" {
+		d, err := status.ParseDuration(opts.Since)
+		if err != nil {
+			return err
+		}
+		since = time.Now().Add(-d)
+	}
+
+	statuses, _, err := fetchStatuses(opts.Logins, opts.Team)
+	if err != nil {
+		return err
+	}
+
+	em := status.NewEmojiManager()
+	emoji := func(shortcode string) string {
+		if opts.Shortcodes || shortcode == "" {
+			return shortcode
+		}
+		return em.ReplaceAll(shortcode)
+	}
+	newRow := func(login string, s *status.Status) reportRow {
+		row := reportRow{Group: availabilityGroup(s), Login: login}
+		if s != nil {
+			row.Emoji = emoji(s.Emoji)
+			row.Message = s.Message
+			row.Limited = s.IndicatesLimitedAvailability
+			row.Expiry = s.ExpiresAt
+		}
+		return row
+	}
+
+	rows := []reportRow{}
+	members := map[string]bool{}
+	for _, ms := range statuses {
+		rows = append(rows, newRow(ms.Login, ms.Status))
+		members[strings.ToLower(ms.Login)] = true
+	}
+	rank := map[string]int{}
+	for i, g := range reportGroups {
+		rank[g] = i
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Group != rows[j].Group {
+			return rank[rows[i].Group] < rank[rows[j].Group]
+		}
+		return strings.ToLower(rows[i].Login) < strings.ToLower(rows[j].Login)
+	})
+
+	changes := []reportChange{}
+	if opts.Since != "" {
+		history, err := readHistory(since)
+		if err != nil {
+			return err
+		}
+		for _, h := range history {
+			if members[strings.ToLower(h.Login)] {
+				changes = append(changes, reportChange{Time: h.Time, reportRow: newRow(h.Login, h.Status)})
+			}
+		}
+	}
+
+	switch opts.Format {
+	case "csv":
+		return writeCSVReport(os.Stdout, rows, changes)
+	case "html":
+		return writeHTMLReport(os.Stdout, rows, changes)
+	default:
+		return writeMarkdownReport(os.Stdout, rows, changes)
+	}
+}
+
+func formatReportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format("Mon Jan 2 15:04")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func writeMarkdownReport(w io.Writer, rows []reportRow, changes []reportChange) error {
+	cell := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, group := range reportGroups {
+		groupRows := []reportRow{}
+		for _, r := range rows {
+			if r.Group == group {
+				groupRows = append(groupRows, r)
+			}
+		}
+		if len(groupRows) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "## %s%s\n\n", strings.ToUpper(group[:1]), group[1:])
+		fmt.Fprintln(w, "| | User | Status | Limited | Until |")
+		fmt.Fprintln(w, "|---|---|---|---|---|")
+		for _, r := range groupRows {
+			fmt.Fprintf(w, "| %s | @%s | %s | %s | %s |\n",
+				r.Emoji, r.Login, cell.Replace(r.Message), yesNo(r.Limited), formatReportTime(r.Expiry))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(changes) > 0 {
+		fmt.Fprintln(w, "## Recent changes")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| When | User | | Status | Limited |")
+		fmt.Fprintln(w, "|---|---|---|---|---|")
+		for _, c := range changes {
+			msg := cell.Replace(c.Message)
+			if msg == "" && c.Emoji == "" {
+				msg = "_cleared_"
+			}
+			fmt.Fprintf(w, "| %s | @%s | %s | %s | %s |\n",
+				formatReportTime(&c.Time), c.Login, c.Emoji, msg, yesNo(c.Limited))
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+func writeCSVReport(w io.Writer, rows []reportRow, changes []reportChange) error {
+	cw := csv.NewWriter(w)
+	rfc3339 := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	_ = cw.Write([]string{"changed_at", "group", "login", "emoji", "message", "limited", "expires_at"})
+	for _, r := range rows {
+		_ = cw.Write([]string{"", r.Group, r.Login, r.Emoji, r.Message, yesNo(r.Limited), rfc3339(r.Expiry)})
+	}
+	for _, c := range changes {
+		_ = cw.Write([]string{rfc3339(&c.Time), c.Group, c.Login, c.Emoji, c.Message, yesNo(c.Limited), rfc3339(c.Expiry)})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"title": func(s string) string { return strings.ToUpper(s[:1]) + s[1:] },
+	"when":  formatReportTime,
+	"at":    func(t time.Time) string { return formatReportTime(&t) },
+	"yesNo": yesNo,
+}).Parse(`<table>
+{{range .Groups}}{{if .Rows}}<tr><th colspan="5">{{title .Name}}</th></tr>
+{{range .Rows}}<tr><td>{{.Emoji}}</td><td>@{{.Login}}</td><td>{{.Message}}</td><td>{{yesNo .Limited}}</td><td>{{when .Expiry}}</td></tr>
+{{end}}{{end}}{{end}}</table>
+{{if .Changes}}<h2>Recent changes</h2>
+<table>
+{{range .Changes}}<tr><td>{{at .Time}}</td><td>@{{.Login}}</td><td>{{.Emoji}}</td><td>{{.Message}}</td><td>{{yesNo .Limited}}</td></tr>
+{{end}}</table>
+{{end}}`))
+
+func writeHTMLReport(w io.Writer, rows []reportRow, changes []reportChange) error {
+	type group struct {
+		Name string
+		Rows []reportRow
+	}
+	groups := []group{}
+	for _, name := range reportGroups {
+		g := group{Name: name}
+		for _, r := range rows {
+			if r.Group == name {
+				g.Rows = append(g.Rows, r)
+			}
+		}
+		groups = append(groups, g)
+	}
+
+	return htmlReport.Execute(w, struct {
+		Groups  []group
+		Changes []reportChange
+	}{groups, changes})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func TestAvailabilityGroup(t *testing.T) {
+	tests := []struct {
+		status *status.Status
+		want   string
+	}{
+		{nil, groupAvailable},
+		{&status.Status{Message: "lunch", Emoji: ":pizza:"}, groupAvailable},
+		{&status.Status{Message: "heads down", Emoji: ":thought_balloon:", IndicatesLimitedAvailability: true}, groupLimited},
+		{&status.Status{Message: "On Vacation", Emoji: ":thought_balloon:"}, groupOut},
+		{&status.Status{Message: "off sick", IndicatesLimitedAvailability: true}, groupOut},
+		{&status.Status{Message: "parental leave until May"}, groupOut},
+		{&status.Status{Emoji: ":palm_tree:"}, groupOut},
+		{&status.Status{Message: "focus time", Emoji: ":no_entry:", IndicatesLimitedAvailability: true}, groupLimited},
+	}
+	for _, tt := range tests {
+		if got := availabilityGroup(tt.status); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestAvailabilityGroupMatchesCalendarRules(t *testing.T) {
+	for _, rule := range defaultCalendarRules.Rules[:2] {
+		for _, kw := range rule.Keywords {
+			s := &status.Status{Message: strings.ToUpper(kw), Emoji: ":" + rule.Emoji + ":"}
+			if got := availabilityGroup(s); got != groupOut {
+				t.Errorf("%q: expected a calendar time off status to count as out, got %q", kw, got)
+			}
+		}
+	}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	rows := []reportRow{
+		{Group: groupOut, Login: "samcoe", Emoji: "🌴", Message: "vacation"},
+		{Group: groupLimited, Login: "mislav", Emoji: "💭", Message: "heads | down", Limited: true},
+		{Group: groupAvailable, Login: "vilmibm", Emoji: "🍕", Message: "lunch"},
+	}
+	var out bytes.Buffer
+	if err := writeMarkdownReport(&out, rows, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	headings := []string{"## Out", "## Limited", "## Available"}
+	last := -1
+	for _, h := range headings {
+		i := strings.Index(got, h)
+		if i < last {
+			t.Errorf("expected %q after the previous group in:\n%s", h, got)
+		}
+		last = i
+	}
+	for _, want := range []string{
+		"| 🌴 | @samcoe | vacation | no |  |",
+		`| 💭 | @mislav | heads \| down | yes |  |`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Recent changes") {
+		t.Errorf("expected no changes section without history:\n%s", got)
+	}
+}
+
+func TestWriteMarkdownReportSkipsEmptyGroups(t *testing.T) {
+	rows := []reportRow{{Group: groupAvailable, Login: "vilmibm"}}
+	var out bytes.Buffer
+	if err := writeMarkdownReport(&out, rows, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); strings.Contains(got, "## Out") || strings.Contains(got, "## Limited") {
+		t.Errorf("expected only the available group, got:\n%s", got)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	setupTest(t)
+
+	now := time.Now()
+	old := historyEntry{Time: now.Add(-historyRetention - time.Hour), Login: "vilmibm"}
+	recent := historyEntry{Time: now.Add(-time.Hour), Login: "mislav", Status: &status.Status{Message: "lunch"}}
+	if err := appendHistory(old, recent); err != nil {
+		t.Fatal(err)
+	}
+	path, err := historyFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneHistory(path, now.Add(-historyRetention)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readHistory(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Login != "mislav" || entries[0].Status.Message != "lunch" {
+		t.Errorf("expected only the recent change to be kept, got %+v", entries)
+	}
+}
+
+func TestAppendHistoryPrunesLargeFile(t *testing.T) {
+	setupTest(t)
+
+	path, err := historyFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := json.Marshal(historyEntry{Time: time.Now().Add(-historyRetention - time.Hour), Login: "vilmibm",
+		Status: &status.Status{Message: strings.Repeat("x", 1024)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Repeat(append(old, '\n'), historyPruneSize/len(old))
+	if err := ioutil.WriteFile(path, lines, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(historyEntry{Time: time.Now(), Login: "mislav"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 1024 {
+		t.Errorf("expected old changes to be pruned, history is %d bytes", fi.Size())
+	}
+	entries, err := readHistory(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Login != "mislav" {
+		t.Errorf("expected only the new change to be kept, got %+v", entries)
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// scheduleEntry is a status to be set at Start and, if End is set, cleared
+// again at End.
+type scheduleEntry struct {
+	ID      int
+	Start   time.Time
+	End     *time.Time `json:",omitempty"`
+	Message string
+	Emoji   string
+	Limited bool
+	// Applied records that the status was set, so that a status the user
+	// changes by hand during the window is not set again on the next run.
+	Applied bool
+}
+
+type schedule struct {
+	Entries []scheduleEntry
+}
+
+func scheduleFile() (string, error) {
+	return configPath("schedule.json")
+}
+
+func loadSchedule() (*schedule, error) {
+	path, err := scheduleFile()
+	if err != nil {
+		return nil, err
+	}
+	s := &schedule{}
+	if err := readJSONFile(path, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *schedule) save() error {
+	path, err := scheduleFile()
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(path, s)
+}
+
+func (s *schedule) add(e scheduleEntry) scheduleEntry {
+	for _, existing := range s.Entries {
+		if existing.ID >= e.ID {
+			e.ID = existing.ID + 1
+		}
+	}
+	if e.ID == 0 {
+		e.ID = 1
+	}
+	s.Entries = append(s.Entries, e)
+	return e
+}
+
+func scheduleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "set and clear your status at future times",
+	}
+	cmd.AddCommand(scheduleAddCmd())
+	cmd.AddCommand(scheduleListCmd())
+	cmd.AddCommand(scheduleRemoveCmd())
+	cmd.AddCommand(scheduleRunCmd())
+
+	return cmd
+}
+
+type scheduleAddOptions struct {
+	Message string
+	Emoji   string
+	Limited bool
+	At      string
+	Until   string
+}
+
+func scheduleAddCmd() *cobra.Command {
+	opts := scheduleAddOptions{}
+	cmd := &cobra.Command{
+		Use:   "add <status>",
+		Short: "queue a status to be set later",
+		Long: `Queue a status to be set at a future time and optionally cleared again.
+
+Times may be given as "2006-01-02 15:04", "2006-01-02", an RFC 3339 timestamp,
+"15:04" for the next occurrence of that time, or "fri 17:00" for the next
+occurrence of that weekday.
+
+Queued statuses are applied by "gh user-status schedule run", which is meant
+to be called regularly from cron or a systemd timer.`,
+		Example: `  gh user-status schedule add --at "fri 17:00" --until "mon 09:00" -e palm_tree -l "OOO"`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Message = args[0]
+			return runScheduleAdd(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Emoji, "emoji", "e", "thought_balloon", "Emoji for status")
+	cmd.Flags().BoolVarP(&opts.Limited, "limited", "l", false, "Indicate limited availability")
+	cmd.Flags().StringVarP(&opts.At, "at", "a", "", "When to set the status")
+	cmd.Flags().StringVarP(&opts.Until, "until", "u", "", "When to clear the status")
+	_ = cmd.MarkFlagRequired("at")
+
+	return cmd
+}
+
+func runScheduleAdd(opts scheduleAddOptions) error {
+	// Catch what GitHub would reject now, rather than when schedule run gets
+	// to it with nobody watching.
+	em := status.NewEmojiManager()
+	if err := validateStatus(em, opts.Emoji, opts.Message); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	start, err := parseWhen(opts.At, now)
+	if err != nil {
+		return err
+	}
+	entry := scheduleEntry{
+		Start:   start,
+		Message: opts.Message,
+		Emoji:   strings.Trim(opts.Emoji, ":"),
+		Limited: opts.Limited,
+	}
+	if opts.Until != "" {
+		end, err := parseWhen(opts.Until, start)
+		if err != nil {
+			return err
+		}
+		if !end.After(start) {
+			return errors.New("--until must be after --at")
+		}
+		entry.End = &end
+	}
+
+	s, err := loadSchedule()
+	if err != nil {
+		return err
+	}
+	entry = s.add(entry)
+	if err := s.save(); err != nil {
+		return err
+	}
+
+	fmt.Println(em.ReplaceAll(fmt.Sprintf("✓ Scheduled #%d %s", entry.ID, describeEntry(entry))))
+
+	return nil
+}
+
+func describeEntry(e scheduleEntry) string {
+	desc := fmt.Sprintf(":%s: %s from %s", e.Emoji, e.Message, e.Start.Local().Format("Mon Jan 2 15:04"))
+	if e.End != nil {
+		desc += fmt.Sprintf(" until %s", e.End.Local().Format("Mon Jan 2 15:04"))
+	}
+	if e.Limited {
+		desc += " (availability is limited)"
+	}
+	return desc
+}
+
+func scheduleListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list queued statuses",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScheduleList()
+		},
+	}
+}
+
+func runScheduleList() error {
+	s, err := loadSchedule()
+	if err != nil {
+		return err
+	}
+	if len(s.Entries) == 0 {
+		fmt.Println("No statuses are scheduled")
+		return nil
+	}
+
+	em := status.NewEmojiManager()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTART\tEND\tLIMITED\tSTATUS")
+	for _, e := range s.Entries {
+		end := "-"
+		if e.End != nil {
+			end = e.End.Local().Format("Mon Jan 2 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n",
+			e.ID, e.Start.Local().Format("Mon Jan 2 15:04"), end, e.Limited,
+			em.ReplaceAll(fmt.Sprintf(":%s: %s", e.Emoji, e.Message)))
+	}
+
+	return w.Flush()
+}
+
+func scheduleRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove [<id>...]",
+		Short: "remove queued statuses",
+		Long:  "Remove the scheduled statuses with the given ids, or choose which to remove when no ids are given.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				ids, err := chooseScheduleEntries()
+				if err != nil {
+					return err
+				}
+				if len(ids) == 0 {
+					return nil
+				}
+				return runScheduleRemove(ids)
+			}
+			ids := []int{}
+			for _, arg := range args {
+				id, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("invalid id %q", arg)
+				}
+				ids = append(ids, id)
+			}
+			return runScheduleRemove(ids)
+		},
+	}
+}
+
+// chooseScheduleEntries asks which scheduled statuses to remove, returning
+// their ids.
+func chooseScheduleEntries() ([]int, error) {
+	s, err := loadSchedule()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Entries) == 0 {
+		fmt.Println("No statuses are scheduled")
+		return nil, nil
+	}
+	if !stdinIsTerminal() {
+		return nil, errors.New("no ids given; run `gh user-status schedule list` to find them")
+	}
+
+	em := status.NewEmojiManager()
+	options := []string{}
+	for _, e := range s.Entries {
+		options = append(options, em.ReplaceAll(fmt.Sprintf("#%d %s", e.ID, describeEntry(e))))
+	}
+	chosen, err := prompts.MultiSelect("Remove which scheduled statuses?", options, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for _, i := range chosen {
+		ids = append(ids, s.Entries[i].ID)
+	}
+	return ids, nil
+}
+
+func runScheduleRemove(ids []int) error {
+	s, err := loadSchedule()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		found := false
+		for i, e := range s.Entries {
+			if e.ID == id {
+				s.Entries = append(s.Entries[:i], s.Entries[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no scheduled status with id %d", id)
+		}
+	}
+
+	if err := s.save(); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Removed %d scheduled status(es)\n", len(ids))
+
+	return nil
+}
+
+func scheduleRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "apply any queued statuses that are due",
+		Long: `Set or clear any queued statuses that are due.
+
+This is safe to run as often as you like: your current status is checked
+before anything is changed, and a status is only cleared if it is still the
+one that was scheduled. A status that can't be applied is reported without
+holding up the rest, and tried again on the next run unless GitHub would
+never accept it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScheduleRun(time.Now())
+		},
+	}
+}
+
+func runScheduleRun(now time.Time) error {
+	s, err := loadSchedule()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(s.Entries, func(i, j int) bool {
+		return s.Entries[i].Start.Before(s.Entries[j].Start)
+	})
+
+	// Only look up the current status once something is due.
+	var current *status.Status
+	currentStatus := func() (*status.Status, error) {
+		if current != nil {
+			return current, nil
+		}
+		var err error
+		current, err = apiStatus("")
+		return current, err
+	}
+
+	em := status.NewEmojiManager()
+	failed := 0
+	report := func(e scheduleEntry, err error) {
+		fmt.Fprintf(os.Stderr, "! Scheduled status #%d: %s\n", e.ID, err)
+		failed++
+	}
+
+	remaining := []scheduleEntry{}
+	for _, e := range s.Entries {
+		if now.Before(e.Start) {
+			remaining = append(remaining, e)
+			continue
+		}
+
+		if e.End != nil && !now.Before(*e.End) {
+			if e.Applied {
+				cur, err := currentStatus()
+				if err != nil {
+					report(e, err)
+					remaining = append(remaining, e)
+					continue
+				}
+				if entryMatches(e, cur) {
+					if err := runClear(); err != nil {
+						report(e, err)
+						remaining = append(remaining, e)
+						continue
+					}
+					current = &status.Status{}
+				}
+			}
+			continue
+		}
+
+		if !e.Applied {
+			if err := validateStatus(em, e.Emoji, e.Message); err != nil {
+				report(e, fmt.Errorf("%w; removing it", err))
+				continue
+			}
+			cur, err := currentStatus()
+			if err != nil {
+				report(e, err)
+				remaining = append(remaining, e)
+				continue
+			}
+			if !entryMatches(e, cur) || cur.IndicatesLimitedAvailability != e.Limited {
+				opts := setOptions{
+					Message: e.Message,
+					Emoji:   e.Emoji,
+					Limited: e.Limited,
+				}
+				if e.End != nil {
+					opts.Expiry = e.End.Sub(now)
+				}
+				if err := runSet(opts); err != nil {
+					report(e, err)
+					remaining = append(remaining, e)
+					continue
+				}
+				current = &status.Status{
+					Message:                      e.Message,
+					Emoji:                        fmt.Sprintf(":%s:", e.Emoji),
+					IndicatesLimitedAvailability: e.Limited,
+				}
+			}
+			e.Applied = true
+		}
+
+		if e.End != nil {
+			remaining = append(remaining, e)
+		}
+	}
+
+	s.Entries = remaining
+	if err := s.save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d scheduled status(es) could not be applied", failed)
+	}
+	return nil
+}
+
+// entryMatches reports whether s is the status e would set.
+func entryMatches(e scheduleEntry, s *status.Status) bool {
+	return s != nil && s.Message == e.Message && s.Emoji == fmt.Sprintf(":%s:", e.Emoji)
+}
+
+var whenLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseWhen parses a point in time given on the command line. Times without
+// a date refer to their next occurrence after from.
+func parseWhen(s string, from time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range whenLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	fields := strings.Fields(strings.ToLower(s))
+	clock := "00:00"
+	weekday := -1
+	switch len(fields) {
+	case 1:
+		if wd, ok := parseWeekday(fields[0]); ok {
+			weekday = wd
+		} else {
+			clock = fields[0]
+		}
+	case 2:
+		wd, ok := parseWeekday(fields[0])
+		if !ok {
+			return time.Time{}, fmt.Errorf("could not parse time %q", s)
+		}
+		weekday = wd
+		clock = fields[1]
+	default:
+		return time.Time{}, fmt.Errorf("could not parse time %q", s)
+	}
+
+	hm, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse time %q", s)
+	}
+
+	t := time.Date(from.Year(), from.Month(), from.Day(), hm.Hour(), hm.Minute(), 0, 0, from.Location())
+	if weekday >= 0 {
+		t = t.AddDate(0, 0, (weekday-int(t.Weekday())+7)%7)
+		if !t.After(from) {
+			t = t.AddDate(0, 0, 7)
+		}
+	} else if !t.After(from) {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+func parseWeekday(s string) (int, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return int(d), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// A Friday afternoon.
+	from := time.Date(2021, 6, 4, 16, 0, 0, 0, time.Local)
+	tests := map[string]time.Time{
+		"2021-06-10 09:30":     time.Date(2021, 6, 10, 9, 30, 0, 0, time.Local),
+		"2021-06-10T09:30":     time.Date(2021, 6, 10, 9, 30, 0, 0, time.Local),
+		"2021-06-10":           time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local),
+		"2021-06-10T09:30:00Z": time.Date(2021, 6, 10, 9, 30, 0, 0, time.UTC),
+		"17:00":                time.Date(2021, 6, 4, 17, 0, 0, 0, time.Local),
+		"09:00":                time.Date(2021, 6, 5, 9, 0, 0, 0, time.Local),
+		"mon 09:00":            time.Date(2021, 6, 7, 9, 0, 0, 0, time.Local),
+		"Friday 17:00":         time.Date(2021, 6, 4, 17, 0, 0, 0, time.Local),
+		"fri 15:00":            time.Date(2021, 6, 11, 15, 0, 0, 0, time.Local),
+		"sat":                  time.Date(2021, 6, 5, 0, 0, 0, 0, time.Local),
+	}
+	for in, want := range tests {
+		got, err := parseWhen(in, from)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", in, err)
+		} else if !got.Equal(want) {
+			t.Errorf("%q: got %s, want %s", in, got, want)
+		}
+	}
+	for _, in := range []string{"soon", "someday 17:00", "fri 25:00", "next fri 17:00"} {
+		if _, err := parseWhen(in, from); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+// writeSchedule replaces the schedule with entries.
+func writeSchedule(t *testing.T, entries ...scheduleEntry) {
+	t.Helper()
+	s := &schedule{}
+	for _, e := range entries {
+		s.add(e)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readSchedule(t *testing.T) []scheduleEntry {
+	t.Helper()
+	s, err := loadSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Entries
+}
+
+func TestScheduleRunNotDue(t *testing.T) {
+	f := setupTest(t)
+	now := time.Now()
+	writeSchedule(t, scheduleEntry{Start: now.Add(time.Hour), Message: "lunch", Emoji: "pizza"})
+
+	if err := runScheduleRun(now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("expected no requests, got %q", f.calls)
+	}
+	if entries := readSchedule(t); len(entries) != 1 || entries[0].Applied {
+		t.Errorf("expected the entry to stay queued, got %+v", entries)
+	}
+}
+
+func TestScheduleRunSetsDueStatus(t *testing.T) {
+	setupTest(t, "get_viewer", "set_pizza")
+	now := time.Now()
+	writeSchedule(t, scheduleEntry{Start: now.Add(-time.Minute), Message: "lunch", Emoji: "pizza"})
+
+	if err := runScheduleRun(now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if entries := readSchedule(t); len(entries) != 0 {
+		t.Errorf("expected the entry to be done with, got %+v", entries)
+	}
+}
+
+func TestScheduleRunAlreadyMatching(t *testing.T) {
+	f := setupTest(t, "get_viewer")
+	now := time.Now()
+	end := now.Add(time.Hour)
+	writeSchedule(t, scheduleEntry{Start: now.Add(-time.Minute), End: &end, Message: "on vacation", Emoji: "palm_tree", Limited: true})
+
+	for i := 0; i < 2; i++ {
+		if err := runScheduleRun(now); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected only the status to be checked once, got %q", f.calls)
+	}
+	if entries := readSchedule(t); len(entries) != 1 || !entries[0].Applied {
+		t.Errorf("expected the entry to be kept until it ends, got %+v", entries)
+	}
+}
+
+func TestScheduleRunClearsAtEnd(t *testing.T) {
+	setupTest(t, "get_viewer", "clear")
+	now := time.Now()
+	end := now.Add(-time.Minute)
+	writeSchedule(t, scheduleEntry{Start: now.Add(-time.Hour), End: &end, Message: "on vacation", Emoji: "palm_tree", Limited: true, Applied: true})
+
+	if err := runScheduleRun(now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if entries := readSchedule(t); len(entries) != 0 {
+		t.Errorf("expected the entry to be done with, got %+v", entries)
+	}
+}
+
+func TestScheduleRunLeavesChangedStatusAtEnd(t *testing.T) {
+	f := setupTest(t, "get_viewer_hash")
+	now := time.Now()
+	end := now.Add(-time.Minute)
+	writeSchedule(t, scheduleEntry{Start: now.Add(-time.Hour), End: &end, Message: "on vacation", Emoji: "palm_tree", Limited: true, Applied: true})
+
+	if err := runScheduleRun(now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected a status set by hand not to be cleared, got %q", f.calls)
+	}
+	if entries := readSchedule(t); len(entries) != 0 {
+		t.Errorf("expected the entry to be done with, got %+v", entries)
+	}
+}
+
+func TestScheduleRunContinuesPastBadEntry(t *testing.T) {
+	setupTest(t, "get_viewer", "set_pizza")
+	now := time.Now()
+	writeSchedule(t,
+		scheduleEntry{Start: now.Add(-2 * time.Minute), Message: strings.Repeat("x", 90), Emoji: "pizza"},
+		scheduleEntry{Start: now.Add(-time.Minute), Message: "lunch", Emoji: "pizza"},
+	)
+
+	err := runScheduleRun(now)
+	if err == nil || !strings.Contains(err.Error(), "1 scheduled status(es) could not be applied") {
+		t.Errorf("expected the bad entry to be reported, got %v", err)
+	}
+	if entries := readSchedule(t); len(entries) != 0 {
+		t.Errorf("expected both entries to be done with, got %+v", entries)
+	}
+}
+
+func TestScheduleRunRetriesFailedSet(t *testing.T) {
+	setupTest(t, "get_viewer", "set_insufficient_scopes")
+	now := time.Now()
+	writeSchedule(t, scheduleEntry{Start: now.Add(-time.Minute), Message: "lunch", Emoji: "thought_balloon"})
+
+	if err := runScheduleRun(now); err == nil {
+		t.Error("expected the failure to be reported")
+	}
+	if entries := readSchedule(t); len(entries) != 1 || entries[0].Applied {
+		t.Errorf("expected the entry to be tried again, got %+v", entries)
+	}
+}
+
+func TestScheduleAddValidates(t *testing.T) {
+	for _, args := range [][]string{
+		{"-e", "not_an_emoji", "lunch"},
+		{strings.Repeat("x", 81)},
+	} {
+		setupTest(t)
+		_, err := runCommand(t, append([]string{"schedule", "add", "--at", "fri 17:00"}, args...)...)
+		if err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+		if entries := readSchedule(t); len(entries) != 0 {
+			t.Errorf("%q: expected nothing to be scheduled, got %+v", args, entries)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+type serveOptions struct {
+	Logins         []string
+	Team           string
+	Listen         string
+	Interval       time.Duration
+	ExpiringWithin time.Duration
+	ShowOrgOnly    bool
+}
+
+func serveCmd() *cobra.Command {
+	opts := serveOptions{}
+	cmd := &cobra.Command{
+		Use:   "serve [<username>...]",
+		Short: "serve statuses over HTTP",
+		Long: `Poll the statuses of the given users, or of every member of a team, and serve
+them over HTTP so that dashboards can read them without a GitHub token.
+
+  /               a simple HTML page
+  /statuses.json  the statuses as JSON, with an ETag for cheap polling
+  /metrics        availability and API metrics in Prometheus format
+  /healthz        200 while polling is succeeding, 503 otherwise
+
+Statuses that are only visible to members of an organization are served as no
+status, since anyone who can reach the server can read them. Pass
+--show-org-statuses to serve them anyway.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Logins = args
+			if err := checkTargets(opts.Logins, opts.Team); err != nil {
+				return err
+			}
+			if err := checkPollInterval(opts.Interval); err != nil {
+				return err
+			}
+			return runServe(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Team, "team", "t", "", "Serve every member of an <org>/<team>")
+	cmd.Flags().StringVarP(&opts.Listen, "listen", "L", "localhost:8080", "Address to listen on")
+	cmd.Flags().DurationVarP(&opts.Interval, "interval", "i", time.Minute, "How often to refresh statuses")
+	cmd.Flags().DurationVar(&opts.ExpiringWithin, "expiring-within", 4*time.Hour, "Count statuses expiring within this long in /metrics")
+	cmd.Flags().BoolVar(&opts.ShowOrgOnly, "show-org-statuses", false, "Also serve statuses limited to an organization's members")
+
+	return cmd
+}
+
+// statusJSON is how a member's status is presented to HTTP clients.
+type statusJSON struct {
+	Login     string     `json:"login"`
+	Message   string     `json:"message"`
+	Emoji     string     `json:"emoji"`
+	EmojiText string     `json:"emojiText"`
+	Limited   bool       `json:"limited"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	UpdatedAt *time.Time `json:"updatedAt"`
+	HasStatus bool       `json:"hasStatus"`
+	Org       string     `json:"org,omitempty"`
+}
+
+func newStatusJSON(em status.EmojiManager, ms status.MemberStatus) statusJSON {
+	sj := statusJSON{Login: ms.Login}
+	s := ms.Status
+	if s == nil || (s.Message == "" && s.Emoji == "") {
+		return sj
+	}
+	sj.HasStatus = true
+	sj.Message = s.Message
+	sj.Emoji = s.Emoji
+	sj.EmojiText = em.ReplaceAll(s.Emoji)
+	sj.Limited = s.IndicatesLimitedAvailability
+	sj.ExpiresAt = s.ExpiresAt
+	if !s.UpdatedAt.IsZero() {
+		updatedAt := s.UpdatedAt
+		sj.UpdatedAt = &updatedAt
+	}
+	if s.Organization != nil {
+		sj.Org = s.Organization.Login
+	}
+	return sj
+}
+
+// statusServer holds the latest poll results and serves them.
+type statusServer struct {
+	em             status.EmojiManager
+	interval       time.Duration
+	expiringWithin time.Duration
+	showOrgOnly    bool
+
+	mu        sync.RWMutex
+	statuses  []status.MemberStatus
+	members   []statusJSON
+	body      []byte
+	etag      string
+	updatedAt time.Time
+	lastErr   error
+}
+
+func (ss *statusServer) update(statuses []status.MemberStatus) {
+	if !ss.showOrgOnly {
+		statuses = hideOrgStatuses(statuses)
+	}
+	members := []statusJSON{}
+	for _, ms := range statuses {
+		members = append(members, newStatusJSON(ss.em, ms))
+	}
+	body, err := json.MarshalIndent(struct {
+		Members []statusJSON `json:"members"`
+	}{members}, "", "  ")
+	if err != nil {
+		ss.setError(err)
+		return
+	}
+	sum := sha256.Sum256(body)
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.statuses = statuses
+	ss.members = members
+	ss.body = body
+	ss.etag = fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+	ss.updatedAt = time.Now()
+	ss.lastErr = nil
+}
+
+// hideOrgStatuses replaces statuses limited to an organization's members with
+// no status.
+func hideOrgStatuses(statuses []status.MemberStatus) []status.MemberStatus {
+	visible := make([]status.MemberStatus, 0, len(statuses))
+	for _, ms := range statuses {
+		if ms.Status != nil && ms.Status.Organization != nil {
+			ms.Status = &status.Status{}
+		}
+		visible = append(visible, ms)
+	}
+	return visible
+}
+
+func (ss *statusServer) setError(err error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.lastErr = err
+}
+
+func (ss *statusServer) handleStatuses(w http.ResponseWriter, r *http.Request) {
+	ss.mu.RLock()
+	body, etag, updatedAt := ss.body, ss.etag, ss.updatedAt
+	ss.mu.RUnlock()
+
+	if body == nil {
+		http.Error(w, "statuses have not been fetched yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag. The
+// header may list several ETags, and weak ones match too (RFC 7232 3.2).
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (ss *statusServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ss.mu.RLock()
+	updatedAt, lastErr := ss.updatedAt, ss.lastErr
+	ss.mu.RUnlock()
+
+	// Allow for a couple of missed polls before declaring ourselves unhealthy.
+	if updatedAt.IsZero() || time.Since(updatedAt) > 3*ss.interval {
+		msg := "no recent successful poll"
+		if lastErr != nil {
+			msg = fmt.Sprintf("%s: %s", msg, lastErr)
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (ss *statusServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	ss.mu.RLock()
+	statuses := ss.statuses
+	ss.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, statuses, ss.expiringWithin, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = buf.WriteTo(w)
+}
+
+var statusPage = template.Must(template.New("statuses").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Statuses</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+td { padding: 0.3em 1em; }
+.limited { color: #9a6700; }
+.none { color: #6e7781; }
+</style>
+</head>
+<body>
+<table>
+{{range .Members}}<tr>
+<td>{{.Login}}</td>
+{{if .HasStatus}}<td>{{.EmojiText}}</td>
+<td>{{.Message}}{{if .Limited}} <span class="limited">(availability is limited)</span>{{end}}</td>
+<td>{{if .ExpiresAt}}until {{.ExpiresAt.Local.Format "Mon Jan 2 15:04"}}{{end}}</td>
+{{else}}<td></td><td class="none">no status</td><td></td>{{end}}
+</tr>
+{{end}}</table>
+<p class="none">Updated {{.UpdatedAt.Local.Format "Mon Jan 2 15:04:05"}}</p>
+</body>
+</html>
+`))
+
+func (ss *statusServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	ss.mu.RLock()
+	data := struct {
+		Members   []statusJSON
+		UpdatedAt time.Time
+	}{ss.members, ss.updatedAt}
+	ss.mu.RUnlock()
+
+	if data.UpdatedAt.IsZero() {
+		http.Error(w, "statuses have not been fetched yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := statusPage.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+func runServe(opts serveOptions) error {
+	ss := &statusServer{
+		em:             status.NewEmojiManager(),
+		interval:       opts.Interval,
+		expiringWithin: opts.ExpiringWithin,
+		showOrgOnly:    opts.ShowOrgOnly,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ss.handleIndex)
+	mux.HandleFunc("/statuses.json", ss.handleStatuses)
+	mux.HandleFunc("/metrics", ss.handleMetrics)
+	mux.HandleFunc("/healthz", ss.handleHealthz)
+	server := &http.Server{Addr: opts.Listen, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := poller{
+		Interval: opts.Interval,
+		Fetch: func() ([]status.MemberStatus, *rateLimit, error) {
+			return fetchStatuses(opts.Logins, opts.Team)
+		},
+	}
+	go p.Run(ctx, ss.update, func(err error, retryIn time.Duration) {
+		ss.setError(err)
+		fmt.Fprintf(os.Stderr, "%s %s; retrying in %s\n", timestamp(), err, retryIn)
+	})
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving statuses on http://%s\n", opts.Listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func newTestStatusServer(showOrgOnly bool) *statusServer {
+	ss := &statusServer{
+		em:          status.NewEmojiManager(),
+		interval:    time.Minute,
+		showOrgOnly: showOrgOnly,
+	}
+	ss.update([]status.MemberStatus{
+		{Login: "mislav", Status: &status.Status{Message: "lunch", Emoji: ":pizza:"}},
+		{Login: "vilmibm", Status: &status.Status{
+			Message:                      "incident review",
+			Emoji:                        ":rotating_light:",
+			IndicatesLimitedAvailability: true,
+			Organization:                 &status.Organization{Login: "github"},
+		}},
+	})
+	return ss
+}
+
+func serveRequest(handler http.HandlerFunc, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestServeStatusesHidesOrgStatuses(t *testing.T) {
+	ss := newTestStatusServer(false)
+
+	w := serveRequest(ss.handleStatuses, "/statuses.json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got HTTP %d", w.Code)
+	}
+	var body struct {
+		Members []statusJSON
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Members) != 2 {
+		t.Fatalf("expected 2 members, got %+v", body.Members)
+	}
+	if m := body.Members[0]; !m.HasStatus || m.Message != "lunch" || m.EmojiText != "🍕" {
+		t.Errorf("unexpected member %+v", m)
+	}
+	if m := body.Members[1]; m.HasStatus || m.Message != "" || m.Org != "" || m.Limited {
+		t.Errorf("expected the org status to be hidden, got %+v", m)
+	}
+
+	w = serveRequest(ss.handleIndex, "/", nil)
+	if page := w.Body.String(); strings.Contains(page, "incident review") || !strings.Contains(page, "lunch") {
+		t.Errorf("expected only the public status on the page:\n%s", page)
+	}
+
+	w = serveRequest(ss.handleMetrics, "/metrics", nil)
+	if metrics := w.Body.String(); !strings.Contains(metrics, "gh_user_status_members_limited 0\n") {
+		t.Errorf("expected the org status to be left out of the metrics:\n%s", metrics)
+	}
+}
+
+func TestServeStatusesShowOrgStatuses(t *testing.T) {
+	ss := newTestStatusServer(true)
+
+	w := serveRequest(ss.handleStatuses, "/statuses.json", nil)
+	if body := w.Body.String(); !strings.Contains(body, `"org": "github"`) || !strings.Contains(body, "incident review") {
+		t.Errorf("expected the org status to be served:\n%s", body)
+	}
+}
+
+func TestServeStatusesETag(t *testing.T) {
+	ss := newTestStatusServer(false)
+
+	w := serveRequest(ss.handleStatuses, "/statuses.json", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	tests := map[string]int{
+		etag:                    http.StatusNotModified,
+		"W/" + etag:             http.StatusNotModified,
+		`"stale", ` + etag:      http.StatusNotModified,
+		`"stale",W/` + etag:     http.StatusNotModified,
+		"*":                     http.StatusNotModified,
+		`"stale"`:               http.StatusOK,
+		strings.Trim(etag, `"`): http.StatusOK,
+	}
+	for header, want := range tests {
+		w := serveRequest(ss.handleStatuses, "/statuses.json", http.Header{"If-None-Match": {header}})
+		if w.Code != want {
+			t.Errorf("If-None-Match %s: got HTTP %d, want %d", header, w.Code, want)
+		}
+	}
+}
+
+func TestServeBeforeFirstPoll(t *testing.T) {
+	ss := &statusServer{em: status.NewEmojiManager(), interval: time.Minute}
+
+	for path, handler := range map[string]http.HandlerFunc{
+		"/statuses.json": ss.handleStatuses,
+		"/":              ss.handleIndex,
+		"/healthz":       ss.handleHealthz,
+	} {
+		if w := serveRequest(handler, path, nil); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: got HTTP %d, want 503", path, w.Code)
+		}
+	}
+
+	ss.update(nil)
+	if w := serveRequest(ss.handleHealthz, "/healthz", nil); w.Code != http.StatusOK {
+		t.Errorf("/healthz: got HTTP %d after a poll", w.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// slackProfile holds the status fields of a Slack user profile.
+type slackProfile struct {
+	StatusText       string `json:"status_text"`
+	StatusEmoji      string `json:"status_emoji"`
+	StatusExpiration int64  `json:"status_expiration"`
+}
+
+// slackClient talks to the subset of the Slack Web API needed to read and
+// write the user's status.
+type slackClient struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+type slackResponse struct {
+	OK      bool
+	Error   string
+	Profile slackProfile
+}
+
+func (c slackClient) do(req *http.Request) (*slackResponse, error) {
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Slack: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Slack returned HTTP %d", resp.StatusCode)
+	}
+	var sr slackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, fmt.Errorf("failed to deserialize Slack response: %w", err)
+	}
+	if !sr.OK {
+		return nil, fmt.Errorf("Slack error: %s", sr.Error)
+	}
+	return &sr, nil
+}
+
+func (c slackClient) getProfile() (*slackProfile, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/users.profile.get", nil)
+	if err != nil {
+		return nil, err
+	}
+	sr, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return &sr.Profile, nil
+}
+
+func (c slackClient) setProfile(p slackProfile) error {
+	body, err := json.Marshal(struct {
+		Profile slackProfile `json:"profile"`
+	}{p})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.BaseURL+"/users.profile.set", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	_, err = c.do(req)
+	return err
+}
+
+// canonicalEmoji maps a shortcode to the primary name the emoji table gives
+// it, which is the one Slack and GitHub are most likely to share. Skin tone
+// modifiers, which Slack appends as ::skin-tone-N, are dropped.
+func canonicalEmoji(em status.EmojiManager, shortcode string) (string, bool) {
+	name := strings.Trim(shortcode, ":")
+	if i := strings.Index(name, "::"); i >= 0 {
+		name = name[:i]
+	}
+	e, ok := em.Lookup(name)
+	if !ok {
+		return name, false
+	}
+	return e.Names[0], true
+}
+
+func syncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "copy your status to or from other services",
+	}
+	cmd.AddCommand(syncSlackCmd())
+
+	return cmd
+}
+
+type syncSlackOptions struct {
+	Direction string
+	Token     string
+	BaseURL   string
+}
+
+func syncSlackCmd() *cobra.Command {
+	opts := syncSlackOptions{}
+	cmd := &cobra.Command{
+		Use:   "slack",
+		Short: "copy your status between GitHub and Slack",
+		Long: `Copy your GitHub status to your Slack profile, or with --direction from-slack,
+copy your Slack status to GitHub.
+
+Emoji are translated through the emoji table; Slack custom emoji have no
+GitHub equivalent and are replaced with :thought_balloon:. Slack has no notion
+of limited availability, so that flag is not carried over.
+
+The Slack token needs the users.profile:read and users.profile:write scopes.
+It is read from --token or the SLACK_TOKEN environment variable.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Token == "" {
+				opts.Token = os.Getenv("SLACK_TOKEN")
+			}
+			if opts.Token == "" {
+				return errors.New("a Slack token is required; pass --token or set SLACK_TOKEN")
+			}
+			switch opts.Direction {
+			case "to-slack", "from-slack":
+			default:
+				return fmt.Errorf("unknown direction %q; use to-slack or from-slack", opts.Direction)
+			}
+			return runSyncSlack(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Direction, "direction", "d", "to-slack", "Which way to copy: to-slack or from-slack")
+	cmd.Flags().StringVar(&opts.Token, "token", "", "Slack user token")
+	cmd.Flags().StringVar(&opts.BaseURL, "base-url", "https://slack.com/api", "Slack Web API base URL")
+
+	return cmd
+}
+
+func runSyncSlack(opts syncSlackOptions) error {
+	client := slackClient{
+		BaseURL: strings.TrimSuffix(opts.BaseURL, "/"),
+		Token:   opts.Token,
+		HTTP:    newHTTPClient(30 * time.Second),
+	}
+	em := status.NewEmojiManager()
+
+	if opts.Direction == "from-slack" {
+		return syncFromSlack(client, em)
+	}
+	return syncToSlack(client, em)
+}
+
+func syncToSlack(client slackClient, em status.EmojiManager) error {
+	s, err := apiStatus("")
+	if err != nil {
+		return err
+	}
+
+	p := slackProfile{}
+	if s.Message != "" || s.Emoji != "" {
+		p.StatusText = s.Message
+		if s.Emoji != "" {
+			name, _ := canonicalEmoji(em, s.Emoji)
+			p.StatusEmoji = fmt.Sprintf(":%s:", name)
+		}
+		if s.ExpiresAt != nil {
+			p.StatusExpiration = s.ExpiresAt.Unix()
+		}
+	}
+
+	if err := client.setProfile(p); err != nil {
+		return err
+	}
+
+	if p.StatusText == "" && p.StatusEmoji == "" {
+		fmt.Println("✓ Slack status cleared")
+	} else {
+		fmt.Println(em.ReplaceAll(fmt.Sprintf("✓ Slack status set to %s %s", s.Emoji, p.StatusText)))
+	}
+
+	return nil
+}
+
+func syncFromSlack(client slackClient, em status.EmojiManager) error {
+	p, err := client.getProfile()
+	if err != nil {
+		return err
+	}
+
+	if p.StatusText == "" && p.StatusEmoji == "" {
+		return runClear()
+	}
+
+	opts := setOptions{Message: p.StatusText, Emoji: "thought_balloon"}
+	if p.StatusEmoji != "" {
+		name, ok := canonicalEmoji(em, p.StatusEmoji)
+		if ok {
+			opts.Emoji = name
+		} else {
+			fmt.Fprintf(os.Stderr, "! No GitHub emoji for %s; using :thought_balloon:\n", p.StatusEmoji)
+		}
+	}
+	if p.StatusExpiration > 0 {
+		opts.Expiry = time.Until(time.Unix(p.StatusExpiration, 0))
+		if opts.Expiry <= 0 {
+			return runClear()
+		}
+	}
+
+	if opts.Message == "" {
+		// runSet would prompt for the message GitHub doesn't need.
+		return applyStatus(em, opts)
+	}
+	return runSet(opts)
+}
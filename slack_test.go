@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeSlack is a Slack Web API serving profile and recording what is set.
+type fakeSlack struct {
+	profile slackProfile
+	error   string
+	set     []slackProfile
+	auth    []string
+}
+
+func newFakeSlack(t *testing.T, profile slackProfile) (*fakeSlack, *httptest.Server) {
+	t.Helper()
+	f := &fakeSlack{profile: profile}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.auth = append(f.auth, r.Header.Get("Authorization"))
+		resp := map[string]interface{}{"ok": f.error == ""}
+		if f.error != "" {
+			resp["error"] = f.error
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/users.profile.get":
+			resp["profile"] = f.profile
+		case r.Method == "POST" && r.URL.Path == "/users.profile.set":
+			var body struct {
+				Profile slackProfile
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode profile: %s", err)
+			}
+			f.set = append(f.set, body.Profile)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func TestSyncToSlack(t *testing.T) {
+	setupTest(t, "get_viewer")
+	slack, srv := newFakeSlack(t, slackProfile{})
+
+	out, err := runCommand(t, "sync", "slack", "--base-url", srv.URL+"/", "--token", "xoxp-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Slack status set to 🌴 on vacation\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	want := slackProfile{StatusText: "on vacation", StatusEmoji: ":palm_tree:"}
+	if len(slack.set) != 1 || slack.set[0] != want {
+		t.Errorf("got profiles %+v, want %+v", slack.set, want)
+	}
+	if len(slack.auth) != 1 || slack.auth[0] != "Bearer xoxp-secret" {
+		t.Errorf("expected the token to be sent, got %q", slack.auth)
+	}
+}
+
+func TestSyncToSlackError(t *testing.T) {
+	setupTest(t, "get_viewer")
+	slack, srv := newFakeSlack(t, slackProfile{})
+	slack.error = "invalid_auth"
+
+	_, err := runCommand(t, "sync", "slack", "--base-url", srv.URL, "--token", "xoxp-secret")
+	if err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("expected the Slack error, got %v", err)
+	}
+}
+
+func TestSyncFromSlack(t *testing.T) {
+	setupTest(t, "set_pizza")
+	_, srv := newFakeSlack(t, slackProfile{StatusText: "lunch", StatusEmoji: ":pizza:"})
+
+	out, err := runCommand(t, "sync", "slack", "-d", "from-slack", "--base-url", srv.URL, "--token", "xoxp-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🍕 lunch\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSyncFromSlackCustomEmoji(t *testing.T) {
+	setupTest(t, "set_thought_balloon")
+	_, srv := newFakeSlack(t, slackProfile{StatusText: "lunch", StatusEmoji: ":party-parrot:"})
+
+	if _, err := runCommand(t, "sync", "slack", "-d", "from-slack", "--base-url", srv.URL, "--token", "xoxp-secret"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestSyncFromSlackCleared(t *testing.T) {
+	setupTest(t, "clear")
+	_, srv := newFakeSlack(t, slackProfile{})
+
+	out, err := runCommand(t, "sync", "slack", "-d", "from-slack", "--base-url", srv.URL, "--token", "xoxp-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status cleared\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSyncFromSlackEmojiOnly(t *testing.T) {
+	setupTest(t, "set_emoji_only")
+	_, srv := newFakeSlack(t, slackProfile{StatusEmoji: ":coffee:"})
+
+	out, err := runCommand(t, "sync", "slack", "-d", "from-slack", "--base-url", srv.URL, "--token", "xoxp-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to ☕\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,305 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cli/safeexec"
+)
+
+// Executor runs gh with the given arguments and IO handles.
+type Executor interface {
+	Run(stdin io.Reader, stdout, stderr io.Writer, args ...string) error
+}
+
+// GHExecutor runs the gh binary found on the PATH.
+type GHExecutor struct{}
+
+// Run implements Executor.
+func (GHExecutor) Run(stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	ghBin, err := safeexec.LookPath("gh")
+	if err != nil {
+		return fmt.Errorf("could not find gh. Is it installed? error: %w", err)
+	}
+
+	cmd := exec.Command(ghBin, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run gh. error: %w", err)
+	}
+
+	return nil
+}
+
+// Client gets and sets statuses through gh.
+type Client struct {
+	// Exec runs gh. NewClient uses GHExecutor.
+	Exec Executor
+	// Observe, if set, is called after every GraphQL request with the
+	// query, how long it took and any error.
+	Observe func(query string, d time.Duration, err error)
+}
+
+// NewClient returns a Client that uses the gh on the PATH.
+func NewClient() *Client {
+	return &Client{Exec: GHExecutor{}}
+}
+
+// GraphQLError is a single entry in the errors array of a GraphQL response.
+type GraphQLError struct {
+	Type    string
+	Message string
+	Path    []interface{}
+}
+
+// GraphQLErrors is returned when a GraphQL response includes errors.
+type GraphQLErrors []GraphQLError
+
+func (ge GraphQLErrors) Error() string {
+	msgs := []string{}
+	for _, e := range ge {
+		msgs = append(msgs, e.Message)
+	}
+	return fmt.Sprintf("GraphQL error: %s", strings.Join(msgs, "; "))
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage
+	Errors GraphQLErrors
+}
+
+// IsInsufficientScopes reports whether err is a GraphQL error caused by the
+// token missing a required OAuth scope.
+func IsInsufficientScopes(err error) bool {
+	var ge GraphQLErrors
+	if !errors.As(err, &ge) {
+		return false
+	}
+	for _, e := range ge {
+		if e.Type == "INSUFFICIENT_SCOPES" {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrEmojiRejected is returned by Set when GitHub doesn't keep the requested
+// emoji, which happens when it doesn't know it.
+var ErrEmojiRejected = errors.New("failed to set status. Perhaps try another emoji")
+
+// GraphQL runs query against the GitHub GraphQL API and decodes the data
+// field of the response into data, which may be nil. Errors reported in the
+// response body are returned as GraphQLErrors, even when gh itself also
+// failed. Variables are sent as JSON alongside the query, so their values
+// arrive literally; pass anything user supplied as a variable rather than
+// formatting it into the query.
+func (c *Client) GraphQL(query string, variables map[string]interface{}, data interface{}) (err error) {
+	// The request goes to gh as a JSON body rather than -f/-F fields, which gh
+	// would read values starting with @ from files and guess the types of.
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	start := time.Now()
+	var sout, eout bytes.Buffer
+	ghErr := c.Exec.Run(bytes.NewReader(body), &sout, &eout, "api", "graphql", "--input", "-")
+	// Only the request counts towards its latency, not decoding the response.
+	elapsed := time.Since(start)
+	if ghErr != nil {
+		ghErr = fmt.Errorf("%w, stderr: %s", ghErr, eout.String())
+	}
+	if c.Observe != nil {
+		defer func() {
+			c.Observe(query, elapsed, err)
+		}()
+	}
+
+	// gh api prints the response body to STDOUT even when it exits non-zero
+	// for a GraphQL error, so try to decode it before giving up.
+	var resp graphQLResponse
+	if err := json.Unmarshal(sout.Bytes(), &resp); err != nil {
+		if ghErr != nil {
+			return ghErr
+		}
+		return fmt.Errorf("failed to deserialize JSON: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	if ghErr != nil {
+		return ghErr
+	}
+
+	if data == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Data, data); err != nil {
+		return fmt.Errorf("failed to deserialize JSON: %w", err)
+	}
+
+	return nil
+}
+
+// Get fetches login's status, or the logged in user's when login is empty.
+// The returned Status is never nil; it is empty when no status is set.
+func (c *Client) Get(login string) (*MemberStatus, error) {
+	key := "user"
+	query := fmt.Sprintf(`query($login: String!) { user(login: $login) { login status { %s }}}`, Fields)
+	variables := map[string]interface{}{"login": login}
+	if login == "" {
+		key = "viewer"
+		query = fmt.Sprintf(`query {viewer { login status { %s }}}`, Fields)
+		variables = nil
+	}
+
+	resp := map[string]*MemberStatus{}
+	if err := c.GraphQL(query, variables, &resp); err != nil {
+		return nil, err
+	}
+
+	ms, ok := resp[key]
+	if !ok || ms == nil {
+		return nil, errors.New("failed to deserialize JSON")
+	}
+	if ms.Status == nil {
+		ms.Status = &Status{}
+	}
+
+	return ms, nil
+}
+
+// SetOptions describes a status to set.
+type SetOptions struct {
+	Message string
+	// Emoji is a shortcode, with or without colons. It defaults to
+	// thought_balloon.
+	Emoji   string
+	Limited bool
+	// Expiry is how long until the status is cleared; zero means never.
+	Expiry time.Duration
+	// OrganizationID limits who can see the status to members of an
+	// organization, as found by OrganizationID. Empty means everyone.
+	OrganizationID string
+}
+
+// SetRequest returns the mutation and variables Set sends for opts, with the
+// expiry counted from now.
+func SetRequest(opts SetOptions, now time.Time) (string, map[string]interface{}) {
+	mutation := `mutation($emoji: String!, $message: String!, $limited: Boolean!, $expiry: DateTime, $organizationId: ID) {
+		changeUserStatus(input: {emoji: $emoji, message: $message, limitedAvailability: $limited, expiresAt: $expiry, organizationId: $organizationId}) {
+			status {
+				message
+				emoji
+			}
+		}
+	}`
+
+	var expiry interface{}
+	if opts.Expiry > time.Duration(0) {
+		expiry = now.Add(opts.Expiry).Format("2006-01-02T15:04:05-0700")
+	}
+
+	var organizationID interface{}
+	if opts.OrganizationID != "" {
+		organizationID = opts.OrganizationID
+	}
+
+	name := strings.Trim(opts.Emoji, ":")
+	if name == "" {
+		name = "thought_balloon"
+	}
+
+	return mutation, map[string]interface{}{
+		"message":        opts.Message,
+		"emoji":          fmt.Sprintf(":%s:", name),
+		"limited":        opts.Limited,
+		"expiry":         expiry,
+		"organizationId": organizationID,
+	}
+}
+
+// Set sets the logged in user's status, returning it as it was set.
+func (c *Client) Set(opts SetOptions) (*Status, error) {
+	now := time.Now()
+	mutation, variables := SetRequest(opts, now)
+	emoji := variables["emoji"].(string)
+
+	var resp struct {
+		ChangeUserStatus struct {
+			Status Status
+		}
+	}
+	if err := c.GraphQL(mutation, variables, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.ChangeUserStatus.Status.Emoji != emoji {
+		return nil, ErrEmojiRejected
+	}
+
+	var expiresAt *time.Time
+	if opts.Expiry > time.Duration(0) {
+		t := now.Add(opts.Expiry)
+		expiresAt = &t
+	}
+
+	return &Status{
+		Message:                      opts.Message,
+		Emoji:                        emoji,
+		IndicatesLimitedAvailability: opts.Limited,
+		ExpiresAt:                    expiresAt,
+		UpdatedAt:                    now,
+	}, nil
+}
+
+// OrganizationID looks up the ID of the organization called login, for
+// SetOptions.OrganizationID.
+func (c *Client) OrganizationID(login string) (string, error) {
+	query := `query($login: String!) {
+		organization(login: $login) {
+			id
+		}
+	}`
+
+	var resp struct {
+		Organization *struct {
+			ID string
+		}
+	}
+	if err := c.GraphQL(query, map[string]interface{}{"login": login}, &resp); err != nil {
+		return "", err
+	}
+	if resp.Organization == nil {
+		return "", fmt.Errorf("could not find organization %q", login)
+	}
+
+	return resp.Organization.ID, nil
+}
+
+// Clear clears the logged in user's status.
+func (c *Client) Clear() error {
+	mutation := `mutation {
+		changeUserStatus(input: {}) {
+			status {
+				message
+			}
+		}
+	}`
+
+	return c.GraphQL(mutation, nil, nil)
+}
@@ -0,0 +1,28 @@
+package status
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var longDurationRE = regexp.MustCompile(`^(\d+)([dw])$`)
+
+// ParseDuration is like time.ParseDuration but also accepts whole days and
+// weeks, as in "7d" or "2w", which are handy for status expiries.
+func ParseDuration(s string) (time.Duration, error) {
+	if m := longDurationRE.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit := 24 * time.Hour
+		if m[2] == "w" {
+			unit *= 7
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -1,26 +1,44 @@
-package main
+package status
 
 import "strings"
 
 // heavily borrowed from https://github.com/yuin/goldmark-emoji/
 
-type emojiManager struct {
-	emojis []emoji
+// EmojiManager knows the emoji GitHub accepts in statuses by their
+// :shortcode: names.
+type EmojiManager struct {
+	emojis []Emoji
 }
 
-func (em emojiManager) Emojis() []emoji {
+// Emojis returns every known emoji.
+func (em EmojiManager) Emojis() []Emoji {
 	return em.emojis
 }
 
-func (em emojiManager) ReplaceAll(s string) string {
+// Lookup finds the emoji with the given name, with or without colons.
+func (em EmojiManager) Lookup(name string) (Emoji, bool) {
+	name = strings.Trim(name, ":")
+	for _, e := range em.emojis {
+		for _, n := range e.Names {
+			if n == name {
+				return e, true
+			}
+		}
+	}
+	return Emoji{}, false
+}
+
+// ReplaceAll renders every space-separated :shortcode: in s as its emoji.
+// Unknown shortcodes are dropped.
+func (em EmojiManager) ReplaceAll(s string) string {
 	out := []string{}
 	bySpace := strings.Split(s, " ")
 	for _, piece := range bySpace {
 		if strings.HasPrefix(piece, ":") && strings.HasSuffix(piece, ":") {
 			for _, e := range em.emojis {
-				for _, n := range e.names {
+				for _, n := range e.Names {
 					if piece == ":"+n+":" {
-						out = append(out, string(e.codepoint))
+						out = append(out, string(e.Codepoint))
 					}
 				}
 			}
@@ -31,22 +49,31 @@ func (em emojiManager) ReplaceAll(s string) string {
 	return strings.Join(out, " ")
 }
 
-type emoji struct {
-	desc      string
-	codepoint []int32
-	names     []string
+// Emoji is a single emoji and the shortcodes it goes by.
+type Emoji struct {
+	Description string
+	Codepoint   []int32
+	// Names are the emoji's shortcodes without colons; the first is the
+	// preferred one.
+	Names []string
+}
+
+// String returns the emoji itself.
+func (e Emoji) String() string {
+	return string(e.Codepoint)
 }
 
-func newEmoji(desc string, codepoint []int32, names ...string) emoji {
-	return emoji{
-		desc:      desc,
-		codepoint: codepoint,
-		names:     names,
+func newEmoji(desc string, codepoint []int32, names ...string) Emoji {
+	return Emoji{
+		Description: desc,
+		Codepoint:   codepoint,
+		Names:       names,
 	}
 }
 
-func newEmojiManager() emojiManager {
-	emojis := []emoji{
+// NewEmojiManager returns an EmojiManager for GitHub's emoji.
+func NewEmojiManager() EmojiManager {
+	emojis := []Emoji{
 		newEmoji("grinning face", []int32{128512}, "grinning"),
 		newEmoji("grinning face with big eyes", []int32{128515}, "smiley"),
 		newEmoji("grinning face with smiling eyes", []int32{128516}, "smile"),
@@ -1784,5 +1811,5 @@ func newEmojiManager() emojiManager {
 		newEmoji("flag: Scotland", []int32{127988, 917607, 917602, 917619, 917603, 917620, 917631}, "scotland"),
 		newEmoji("flag: Wales", []int32{127988, 917607, 917602, 917623, 917612, 917619, 917631}, "wales"),
 	}
-	return emojiManager{emojis: emojis}
+	return EmojiManager{emojis: emojis}
 }
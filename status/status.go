@@ -0,0 +1,61 @@
+// Package status gets and sets GitHub user statuses. It talks to the GitHub
+// GraphQL API through the gh CLI, so it uses whichever account gh is logged
+// in to.
+//
+//	c := status.NewClient()
+//	s, err := c.Set(status.SetOptions{Message: "lunch", Emoji: "pizza", Expiry: time.Hour})
+//	ms, err := c.Get("") // the logged in user's status
+//
+// Setting or clearing a status requires the token's "user" scope; when it is
+// missing the error satisfies IsInsufficientScopes.
+package status
+
+import "time"
+
+// Status is a user's status as GitHub reports it. Emoji is a :shortcode:,
+// which EmojiManager.ReplaceAll renders.
+type Status struct {
+	IndicatesLimitedAvailability bool
+	Message                      string
+	Emoji                        string
+	// ExpiresAt is nil for a status that never expires.
+	ExpiresAt *time.Time
+	UpdatedAt time.Time
+	// Organization is set when the status is only visible to members of
+	// an organization.
+	Organization *Organization
+}
+
+// Organization is the organization a status is limited to.
+type Organization struct {
+	Login string
+}
+
+// Fields selects every field of a UserStatus that Status decodes, for use in
+// GraphQL queries.
+const Fields = `indicatesLimitedAvailability message emoji expiresAt updatedAt organization { login }`
+
+// MemberStatus pairs a login with their status, which is nil when they have
+// not set one.
+type MemberStatus struct {
+	Login  string
+	Status *Status
+}
+
+// Equal reports whether two statuses would look the same to a viewer. A nil
+// status is equal to an empty one.
+func Equal(a, b *Status) bool {
+	if a == nil {
+		a = &Status{}
+	}
+	if b == nil {
+		b = &Status{}
+	}
+	if a.Message != b.Message || a.Emoji != b.Emoji || a.IndicatesLimitedAvailability != b.IndicatesLimitedAvailability {
+		return false
+	}
+	if a.ExpiresAt == nil || b.ExpiresAt == nil {
+		return a.ExpiresAt == nil && b.ExpiresAt == nil
+	}
+	return a.ExpiresAt.Equal(*b.ExpiresAt)
+}
@@ -0,0 +1,242 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replayExecutor answers every gh call with the same output.
+type replayExecutor struct {
+	stdout string
+	err    error
+	args   [][]string
+	stdins []string
+}
+
+func (r *replayExecutor) Run(stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	r.args = append(r.args, args)
+	in := ""
+	if stdin != nil {
+		b, _ := ioutil.ReadAll(stdin)
+		in = string(b)
+	}
+	r.stdins = append(r.stdins, in)
+	_, _ = io.WriteString(stdout, r.stdout)
+	return r.err
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30m": 30 * time.Minute,
+		"1h":  time.Hour,
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"0s":  0,
+	}
+	for in, want := range tests {
+		got, err := ParseDuration(in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", in, err)
+		} else if got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+	if _, err := ParseDuration("soon"); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
+
+func TestEmojiManager(t *testing.T) {
+	em := NewEmojiManager()
+
+	e, ok := em.Lookup(":pizza:")
+	if !ok || e.String() != "🍕" || e.Names[0] != "pizza" {
+		t.Errorf("unexpected lookup result %+v, %t", e, ok)
+	}
+	if _, ok := em.Lookup("not_an_emoji"); ok {
+		t.Error("expected an unknown emoji not to be found")
+	}
+	if got := em.ReplaceAll(":pizza: lunch"); got != "🍕 lunch" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	if !Equal(nil, &Status{}) {
+		t.Error("expected nil to equal an empty status")
+	}
+	if Equal(&Status{Message: "lunch"}, &Status{Message: "lunch", ExpiresAt: &later}) {
+		t.Error("expected statuses with different expiries to differ")
+	}
+}
+
+func TestClientGet(t *testing.T) {
+	exec := &replayExecutor{stdout: `{"data":{"viewer":{"login":"monalisa","status":null}}}`}
+	c := &Client{Exec: exec}
+
+	ms, err := c.Get("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ms.Login != "monalisa" || ms.Status == nil || ms.Status.Message != "" {
+		t.Errorf("unexpected status %+v", ms)
+	}
+}
+
+func TestClientGetUser(t *testing.T) {
+	exec := &replayExecutor{stdout: `{"data":{"user":{"login":"vilmibm","status":{"message":"lunch"}}}}`}
+	c := &Client{Exec: exec}
+
+	login := `vilmibm") { id } x: user(login: "mislav`
+	ms, err := c.Get(login)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ms.Login != "vilmibm" || ms.Status.Message != "lunch" {
+		t.Errorf("unexpected status %+v", ms)
+	}
+
+	var req struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(exec.stdins[0]), &req); err != nil {
+		t.Fatalf("request body isn't JSON: %s", err)
+	}
+	if strings.Contains(req.Query, "vilmibm") || req.Variables["login"] != login {
+		t.Errorf("expected the login to be sent as a variable, got %+v", req)
+	}
+}
+
+func TestClientSet(t *testing.T) {
+	exec := &replayExecutor{stdout: `{"data":{"changeUserStatus":{"status":{"message":"lunch","emoji":":pizza:"}}}}`}
+	c := &Client{Exec: exec}
+
+	s, err := c.Set(SetOptions{Message: "lunch", Emoji: ":pizza:", Expiry: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.Emoji != ":pizza:" || s.ExpiresAt == nil {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if args := strings.Join(exec.args[0], " "); args != "api graphql --input -" {
+		t.Errorf("expected the request on STDIN, got %s", args)
+	}
+	if body := exec.stdins[0]; !strings.Contains(body, `"emoji":":pizza:"`) {
+		t.Errorf("expected the emoji to be sent, got %s", body)
+	}
+
+	_, err = c.Set(SetOptions{Message: "lunch", Emoji: "not_an_emoji"})
+	if !errors.Is(err, ErrEmojiRejected) {
+		t.Errorf("expected ErrEmojiRejected, got %v", err)
+	}
+}
+
+func TestGraphQLSendsValuesLiterally(t *testing.T) {
+	exec := &replayExecutor{stdout: `{"data":{}}`}
+	c := &Client{Exec: exec}
+
+	vars := map[string]interface{}{"message": "@mislav pairing", "count": "42"}
+	if err := c.GraphQL("query { viewer { login } }", vars, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var req struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(exec.stdins[0]), &req); err != nil {
+		t.Fatalf("request body isn't JSON: %s", err)
+	}
+	if req.Query != "query { viewer { login } }" || !reflect.DeepEqual(req.Variables, vars) {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+// slowExecutor takes d to answer each gh call.
+type slowExecutor struct {
+	replayExecutor
+	d time.Duration
+}
+
+func (s *slowExecutor) Run(stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	time.Sleep(s.d)
+	return s.replayExecutor.Run(stdin, stdout, stderr, args...)
+}
+
+func TestGraphQLObserve(t *testing.T) {
+	var observed []time.Duration
+	var errs []error
+	c := &Client{
+		Exec: &slowExecutor{
+			replayExecutor: replayExecutor{stdout: `{"errors":[{"message":"boom"}]}`},
+			d:              20 * time.Millisecond,
+		},
+		Observe: func(query string, d time.Duration, err error) {
+			observed = append(observed, d)
+			errs = append(errs, err)
+		},
+	}
+
+	err := c.GraphQL("query { viewer { login } }", nil, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(observed) != 1 || observed[0] < 20*time.Millisecond {
+		t.Errorf("expected the request's duration to be observed, got %v", observed)
+	}
+	if len(errs) != 1 || errs[0] == nil || errs[0].Error() != err.Error() {
+		t.Errorf("expected the error to be observed, got %v", errs)
+	}
+}
+
+func TestSetRequest(t *testing.T) {
+	now := time.Date(2021, 6, 4, 16, 0, 0, 0, time.UTC)
+	_, vars := SetRequest(SetOptions{Message: "lunch", Expiry: time.Hour, OrganizationID: "O_1"}, now)
+	want := map[string]interface{}{
+		"message":        "lunch",
+		"emoji":          ":thought_balloon:",
+		"limited":        false,
+		"expiry":         "2021-06-04T17:00:00+0000",
+		"organizationId": "O_1",
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("got %v, want %v", vars, want)
+	}
+}
+
+func TestClientOrganizationID(t *testing.T) {
+	c := &Client{Exec: &replayExecutor{stdout: `{"data":{"organization":{"id":"O_1"}}}`}}
+	id, err := c.OrganizationID("cli")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if id != "O_1" {
+		t.Errorf("got %q", id)
+	}
+
+	c = &Client{Exec: &replayExecutor{stdout: `{"data":{"organization":null}}`}}
+	if _, err := c.OrganizationID("nope"); err == nil {
+		t.Error("expected an error for a missing organization")
+	}
+}
+
+func TestInsufficientScopes(t *testing.T) {
+	exec := &replayExecutor{
+		stdout: `{"errors":[{"type":"INSUFFICIENT_SCOPES","message":"Your token has not been granted the required scopes"}]}`,
+		err:    errors.New("exit status 1"),
+	}
+	c := &Client{Exec: exec}
+
+	err := c.Clear()
+	if !IsInsufficientScopes(err) {
+		t.Errorf("expected an insufficient scopes error, got %v", err)
+	}
+}
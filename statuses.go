@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+type rateLimit struct {
+	Remaining int
+	ResetAt   time.Time
+}
+
+// parseTeam splits an org/team argument into its organization and team slug.
+func parseTeam(team string) (org, slug string, err error) {
+	parts := strings.SplitN(team, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid team %q; expected <org>/<team>", team)
+	}
+	return parts[0], parts[1], nil
+}
+
+// checkTargets validates the usernames and --team given to commands that
+// report on several users.
+func checkTargets(logins []string, team string) error {
+	if team == "" && len(logins) == 0 {
+		return errors.New("specify at least one username or --team")
+	}
+	if team != "" && len(logins) > 0 {
+		return errors.New("specify either usernames or --team, not both")
+	}
+	return nil
+}
+
+// minPollInterval is the shortest --interval accepted by commands that poll
+// the API, so that a typo can't burn through the rate limit.
+const minPollInterval = 10 * time.Second
+
+// checkPollInterval validates the --interval given to commands that poll the
+// API.
+func checkPollInterval(interval time.Duration) error {
+	if interval < minPollInterval {
+		return fmt.Errorf("--interval must be at least %s", minPollInterval)
+	}
+	return nil
+}
+
+// fetchStatuses looks up the status of each login, or of every member of team
+// when it is set.
+func fetchStatuses(logins []string, team string) ([]status.MemberStatus, *rateLimit, error) {
+	if team != "" {
+		return teamStatuses(team)
+	}
+	return usersStatuses(logins)
+}
+
+func usersStatuses(logins []string) ([]status.MemberStatus, *rateLimit, error) {
+	if len(logins) == 0 {
+		return nil, nil, errors.New("no users given")
+	}
+
+	params := []string{}
+	fields := []string{}
+	variables := map[string]interface{}{}
+	for i, login := range logins {
+		params = append(params, fmt.Sprintf("$u%d: String!", i))
+		fields = append(fields, fmt.Sprintf("u%d: user(login: $u%d) { login status { %s } }", i, i, status.Fields))
+		variables[fmt.Sprintf("u%d", i)] = login
+	}
+	query := fmt.Sprintf(`query(%s) {
+		%s
+		rateLimit { remaining resetAt }
+	}`, strings.Join(params, ", "), strings.Join(fields, "\n\t\t"))
+
+	// The aliases make the response shape dynamic, so decode each alias
+	// separately.
+	var resp map[string]json.RawMessage
+	err := withUserScope(func() error {
+		return graphQL(query, variables, &resp)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rl rateLimit
+	if err := json.Unmarshal(resp["rateLimit"], &rl); err != nil {
+		return nil, nil, fmt.Errorf("failed to deserialize JSON: %w", err)
+	}
+
+	statuses := []status.MemberStatus{}
+	for i, login := range logins {
+		var ms *status.MemberStatus
+		if err := json.Unmarshal(resp[fmt.Sprintf("u%d", i)], &ms); err != nil {
+			return nil, nil, fmt.Errorf("failed to deserialize JSON: %w", err)
+		}
+		if ms == nil {
+			return nil, nil, fmt.Errorf("could not find user %s", login)
+		}
+		statuses = append(statuses, *ms)
+	}
+
+	return statuses, &rl, nil
+}
+
+func teamStatuses(team string) ([]status.MemberStatus, *rateLimit, error) {
+	org, slug, err := parseTeam(team)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := fmt.Sprintf(`query($org: String!, $team: String!, $after: String) {
+		organization(login: $org) {
+			team(slug: $team) {
+				members(first: 100, after: $after) {
+					nodes { login status { %s } }
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}
+		rateLimit { remaining resetAt }
+	}`, status.Fields)
+
+	var after interface{}
+	var rl rateLimit
+	statuses := []status.MemberStatus{}
+	for {
+		var resp struct {
+			Organization *struct {
+				Team *struct {
+					Members struct {
+						Nodes    []status.MemberStatus
+						PageInfo struct {
+							HasNextPage bool
+							EndCursor   string
+						}
+					}
+				}
+			}
+			RateLimit rateLimit
+		}
+		variables := map[string]interface{}{
+			"org":   org,
+			"team":  slug,
+			"after": after,
+		}
+		err := withUserScope(func() error {
+			return graphQL(query, variables, &resp)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.Organization == nil || resp.Organization.Team == nil {
+			return nil, nil, fmt.Errorf("could not find team %s", team)
+		}
+
+		members := resp.Organization.Team.Members
+		statuses = append(statuses, members.Nodes...)
+		rl = resp.RateLimit
+		if !members.PageInfo.HasNextPage {
+			break
+		}
+		after = members.PageInfo.EndCursor
+	}
+
+	return statuses, &rl, nil
+}
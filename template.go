@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// messageContext is what a message template can refer to.
+type messageContext struct {
+	Now    time.Time
+	Expiry time.Duration
+	// Vars are the preset variables from config.json, overridden by --var.
+	Vars map[string]string
+}
+
+// renderMessage expands a status message written as a Go template, e.g.
+//
+//	OOO until {{expiry | weekday}}
+//	pairing with @{{env.PAIR}}
+//	reviewing {{git.branch}}
+//	on call for {{vars.team}}
+//
+// Messages without {{ are returned as they are. Referring to something that
+// isn't set, like an unset environment variable, is an error rather than an
+// empty string, so that half-rendered statuses are never sent.
+func renderMessage(message string, ctx messageContext) (string, error) {
+	if !strings.Contains(message, "{{") {
+		return message, nil
+	}
+
+	funcs := template.FuncMap{
+		"now": func() time.Time { return ctx.Now },
+		"expiry": func() (time.Time, error) {
+			if ctx.Expiry <= 0 {
+				return time.Time{}, errors.New("{{expiry}} needs an expiry to be set")
+			}
+			return ctx.Now.Add(ctx.Expiry), nil
+		},
+		"weekday": func(t time.Time) string { return t.Weekday().String() },
+		"date":    func(t time.Time) string { return t.Format("Jan 2") },
+		"time":    func(t time.Time) string { return t.Format("15:04") },
+		"format":  func(layout string, t time.Time) string { return t.Format(layout) },
+		"env":     environMap,
+		"git":     gitContext,
+		"vars":    func() map[string]string { return ctx.Vars },
+	}
+	tmpl, err := template.New("message").Option("missingkey=error").Funcs(funcs).Parse(message)
+	if err != nil {
+		return "", fmt.Errorf("invalid message template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", fmt.Errorf("could not expand message: %w", err)
+	}
+	return b.String(), nil
+}
+
+func environMap() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// gitContext describes the repository in the current directory with the same
+// fields git-hook messages use: repo, branch, issue and ref.
+func gitContext() (map[string]string, error) {
+	branch, err := gitOutput("symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		return nil, errors.New("{{git}} needs a branch checked out in a git repository")
+	}
+	repo, err := gitRepoName()
+	if err != nil {
+		return nil, err
+	}
+
+	issue, ref := branchRef(repo, branch)
+	return map[string]string{
+		"repo":   repo,
+		"branch": branch,
+		"issue":  issue,
+		"ref":    ref,
+	}, nil
+}
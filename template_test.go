@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+func TestRenderMessage(t *testing.T) {
+	setenv(t, "PAIR", "mislav")
+	ctx := messageContext{
+		// A Friday.
+		Now:    time.Date(2021, 6, 4, 16, 0, 0, 0, time.UTC),
+		Expiry: 3 * 24 * time.Hour,
+		Vars:   map[string]string{"team": "cli"},
+	}
+
+	tests := map[string]string{
+		"plain message":                   "plain message",
+		"OOO until {{expiry | weekday}}":  "OOO until Monday",
+		"back {{expiry | date}}":          "back Jun 7",
+		"until {{expiry | time}}":         "until 16:00",
+		`{{expiry | format "Mon 2 Jan"}}`: "Mon 7 Jun",
+		"pairing with @{{env.PAIR}}":      "pairing with @mislav",
+		"on call for {{vars.team}}":       "on call for cli",
+		"since {{now | weekday}}":         "since Friday",
+	}
+	for in, want := range tests {
+		got, err := renderMessage(in, ctx)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", in, err)
+		} else if got != want {
+			t.Errorf("%s: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderMessageErrors(t *testing.T) {
+	ctx := messageContext{Now: time.Now()}
+	for _, in := range []string{
+		"OOO until {{expiry | weekday}}",
+		"pairing with @{{env.GH_USER_STATUS_SURELY_UNSET}}",
+		"on call for {{vars.team}}",
+		"unclosed {{",
+	} {
+		if got, err := renderMessage(in, ctx); err == nil {
+			t.Errorf("%s: expected an error, got %q", in, got)
+		}
+	}
+}
+
+func TestSetMessageTooLong(t *testing.T) {
+	f := setupTest(t)
+
+	_, err := runCommand(t, "set", strings.Repeat("a", 81))
+	if err == nil || !strings.Contains(err.Error(), "at most 80") {
+		t.Errorf("expected the message to be rejected, got %v", err)
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("expected no requests, got %q", f.calls)
+	}
+}
+
+func TestSetTemplate(t *testing.T) {
+	setupTest(t, "set_template")
+
+	out, err := runCommand(t, "set", "-e", "pizza", "--var", "place=the park", "lunch at {{vars.place}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "✓ Status set to 🍕 lunch at the park\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestSetTemplateOnlyFromSet(t *testing.T) {
+	setupTest(t)
+
+	message, err := expandMessage(status.NewEmojiManager(), setOptions{Message: "lunch at {{vars.place}}"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if message != "lunch at {{vars.place}}" {
+		t.Errorf("expected the message to be left alone, got %q", message)
+	}
+}
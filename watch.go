@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+type watchOptions struct {
+	Logins   []string
+	Team     string
+	Interval time.Duration
+	Exec     string
+}
+
+func watchCmd() *cobra.Command {
+	opts := watchOptions{}
+	cmd := &cobra.Command{
+		Use:   "watch [<username>...]",
+		Short: "follow changes to GitHub users' statuses",
+		Long: `Poll the statuses of the given users, or of every member of a team, and print
+a line whenever one of them changes. Changes are also recorded in a local
+history that "gh user-status report --since" can include.
+
+When --exec is given, the command is run through sh after each change with the
+following environment variables describing it:
+
+  GH_USER_STATUS_LOGIN
+  GH_USER_STATUS_MESSAGE, GH_USER_STATUS_PREVIOUS_MESSAGE
+  GH_USER_STATUS_EMOJI, GH_USER_STATUS_PREVIOUS_EMOJI
+  GH_USER_STATUS_LIMITED, GH_USER_STATUS_PREVIOUS_LIMITED
+  GH_USER_STATUS_EXPIRES_AT, GH_USER_STATUS_PREVIOUS_EXPIRES_AT`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Logins = args
+			if err := checkTargets(opts.Logins, opts.Team); err != nil {
+				return err
+			}
+			if err := checkPollInterval(opts.Interval); err != nil {
+				return err
+			}
+			return runWatch(opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Team, "team", "t", "", "Watch every member of an <org>/<team>")
+	cmd.Flags().DurationVarP(&opts.Interval, "interval", "i", time.Minute, "How often to check for changes")
+	cmd.Flags().StringVarP(&opts.Exec, "exec", "x", "", "Command to run whenever a status changes")
+
+	return cmd
+}
+
+func runWatch(opts watchOptions) error {
+	em := status.NewEmojiManager()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var last map[string]*status.Status
+	p := poller{
+		Interval: opts.Interval,
+		Fetch: func() ([]status.MemberStatus, *rateLimit, error) {
+			return fetchStatuses(opts.Logins, opts.Team)
+		},
+	}
+	p.Run(ctx, func(statuses []status.MemberStatus) {
+		current := map[string]*status.Status{}
+		for _, ms := range statuses {
+			current[ms.Login] = ms.Status
+			if last == nil {
+				fmt.Println(em.ReplaceAll(describeStatus(ms.Login, ms.Status)))
+				continue
+			}
+			prev, seen := last[ms.Login]
+			if seen && status.Equal(prev, ms.Status) {
+				continue
+			}
+			fmt.Println(em.ReplaceAll(describeStatus(ms.Login, ms.Status)))
+			err := appendHistory(historyEntry{Time: time.Now(), Login: ms.Login, Status: ms.Status})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s could not record history: %s\n", timestamp(), err)
+			}
+			if opts.Exec != "" {
+				if err := runWatchHook(opts.Exec, ms.Login, prev, ms.Status); err != nil {
+					fmt.Fprintf(os.Stderr, "%s --exec failed: %s\n", timestamp(), err)
+				}
+			}
+		}
+		last = current
+	}, func(err error, retryIn time.Duration) {
+		fmt.Fprintf(os.Stderr, "%s %s; retrying in %s\n", timestamp(), err, retryIn)
+	})
+
+	return nil
+}
+
+func timestamp() string {
+	return time.Now().Format("2006-01-02 15:04:05")
+}
+
+// describeStatus renders a timestamped line summarizing login's status.
+func describeStatus(login string, s *status.Status) string {
+	if s == nil || (s.Message == "" && s.Emoji == "") {
+		return fmt.Sprintf("%s %s has no status", timestamp(), login)
+	}
+	line := fmt.Sprintf("%s %s: %s %s", timestamp(), login, s.Emoji, s.Message)
+	if s.IndicatesLimitedAvailability {
+		line += " (availability is limited)"
+	}
+	if s.ExpiresAt != nil {
+		line += fmt.Sprintf(" [expires %s]", s.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+	return line
+}
+
+func runWatchHook(command, login string, prev, cur *status.Status) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GH_USER_STATUS_LOGIN="+login)
+	cmd.Env = append(cmd.Env, statusEnv("GH_USER_STATUS_", cur)...)
+	cmd.Env = append(cmd.Env, statusEnv("GH_USER_STATUS_PREVIOUS_", prev)...)
+
+	return cmd.Run()
+}
+
+func statusEnv(prefix string, s *status.Status) []string {
+	if s == nil {
+		s = &status.Status{}
+	}
+	expiresAt := ""
+	if s.ExpiresAt != nil {
+		expiresAt = s.ExpiresAt.Format(time.RFC3339)
+	}
+	return []string{
+		prefix + "MESSAGE=" + s.Message,
+		prefix + "EMOJI=" + s.Emoji,
+		prefix + "LIMITED=" + strconv.FormatBool(s.IndicatesLimitedAvailability),
+		prefix + "EXPIRES_AT=" + expiresAt,
+	}
+}
+
+// poller repeatedly fetches statuses, backing off when requests fail and
+// waiting out the GraphQL rate limit when it runs low.
+type poller struct {
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	Fetch      func() ([]status.MemberStatus, *rateLimit, error)
+}
+
+// rateLimitReserve is how many GraphQL points the poller leaves unspent for
+// the rest of the user's tooling before it pauses until the limit resets.
+const rateLimitReserve = 100
+
+// Run polls until ctx is done, calling onUpdate with each successful result
+// and onError with each failure and the delay before the next attempt.
+func (p poller) Run(ctx context.Context, onUpdate func([]status.MemberStatus), onError func(error, time.Duration)) {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = 10 * p.Interval
+	}
+
+	delay := p.Interval
+	for {
+		statuses, rl, err := p.Fetch()
+		wait := p.Interval
+		if err != nil {
+			delay *= 2
+			if delay > maxBackoff {
+				delay = maxBackoff
+			}
+			wait = delay
+			onError(err, wait)
+		} else {
+			delay = p.Interval
+			onUpdate(statuses)
+			if rl != nil && rl.Remaining < rateLimitReserve {
+				if untilReset := time.Until(rl.ResetAt); untilReset > wait {
+					wait = untilReset
+					onError(fmt.Errorf("rate limit nearly exhausted (%d remaining)", rl.Remaining), wait)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vilmibm/gh-user-status/status"
+)
+
+// webhookTarget is an endpoint told about every status change.
+type webhookTarget struct {
+	URL string
+	// Template, if set, is a text/template that renders the request body
+	// from the webhookPayload, for services that expect their own format.
+	Template string
+	// Secret, if set, is used to sign the body with HMAC-SHA256 in the
+	// X-Gh-User-Status-Signature header.
+	Secret string
+}
+
+// webhookPayload is the JSON document sent to webhook targets.
+type webhookPayload struct {
+	Event     string      `json:"event"`
+	Login     string      `json:"login"`
+	Old       *statusJSON `json:"old"`
+	New       *statusJSON `json:"new"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+const (
+	webhookAttempts = 3
+	webhookBackoff  = time.Second
+	webhookTimeout  = 10 * time.Second
+)
+
+// webhookNotifier sends the change from the status it saw when it was created
+// to a new one. A nil notifier does nothing.
+type webhookNotifier struct {
+	targets []webhookTarget
+	login   string
+	old     *status.Status
+}
+
+// newWebhookNotifier loads the configured webhook targets and, if there are
+// any, records the current status so that they can be sent the old and new
+// statuses. Problems are reported as warnings since webhooks must never stop
+// a status change.
+func newWebhookNotifier() *webhookNotifier {
+	c, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "! Webhooks disabled: %s\n", err)
+		return nil
+	}
+	if len(c.Webhooks) == 0 {
+		return nil
+	}
+
+	n := &webhookNotifier{targets: c.Webhooks}
+	ms, err := fetchStatus("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "! Could not read your current status for webhooks: %s\n", err)
+		return n
+	}
+	n.login = ms.Login
+	n.old = ms.Status
+
+	return n
+}
+
+// notify sends the change to every target, reporting failures on stderr. cur
+// is nil when the status was cleared.
+func (n *webhookNotifier) notify(cur *status.Status) {
+	if n == nil {
+		return
+	}
+
+	em := status.NewEmojiManager()
+	toJSON := func(s *status.Status) *statusJSON {
+		if s == nil || (s.Message == "" && s.Emoji == "") {
+			return nil
+		}
+		sj := newStatusJSON(em, status.MemberStatus{Login: n.login, Status: s})
+		return &sj
+	}
+	payload := webhookPayload{
+		Event:     "status.changed",
+		Login:     n.login,
+		Old:       toJSON(n.old),
+		New:       toJSON(cur),
+		Timestamp: time.Now().UTC(),
+	}
+	if payload.New == nil {
+		payload.Event = "status.cleared"
+	}
+
+	client := newHTTPClient(webhookTimeout)
+	for _, t := range n.targets {
+		deliverWebhook(client, t, payload)
+	}
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func renderWebhookBody(t webhookTarget, payload webhookPayload) ([]byte, error) {
+	if t.Template == "" {
+		return json.Marshal(payload)
+	}
+	tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(t.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// webhookDelivery is a rendered and signed webhook request. Deliveries that
+// fail are saved as they are, so the secret never has to be written down.
+type webhookDelivery struct {
+	URL       string
+	Body      []byte
+	Signature string
+}
+
+func newWebhookDelivery(t webhookTarget, payload webhookPayload) (webhookDelivery, error) {
+	body, err := renderWebhookBody(t, payload)
+	if err != nil {
+		return webhookDelivery{}, err
+	}
+	d := webhookDelivery{URL: t.URL, Body: body}
+	if t.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(t.Secret))
+		mac.Write(body)
+		d.Signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return d, nil
+}
+
+// deliverWebhook makes one attempt to send payload to t. Rather than keep the
+// user waiting, a failure worth retrying is handed to a background process.
+func deliverWebhook(client *http.Client, t webhookTarget, payload webhookPayload) {
+	d, err := newWebhookDelivery(t, payload)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "! Webhook %s failed: %s\n", t.URL, err)
+		return
+	}
+	retry, err := d.post(client)
+	if err == nil {
+		return
+	}
+	if retry {
+		if qerr := queueWebhookRetry(d); qerr == nil {
+			fmt.Fprintf(os.Stderr, "! Webhook %s failed: %s; retrying in the background\n", t.URL, err)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "! Webhook %s failed: %s\n", t.URL, err)
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth
+// retrying.
+func (d webhookDelivery) post(client *http.Client) (bool, error) {
+	req, err := http.NewRequest("POST", d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gh-user-status")
+	if d.Signature != "" {
+		req.Header.Set("X-Gh-User-Status-Signature", d.Signature)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("HTTP %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// queueWebhookRetry saves d in the cache directory and starts a background
+// process to retry it.
+func queueWebhookRetry(d webhookDelivery) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "webhook-*.json")
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(d)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = startWebhookRetry(f.Name())
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// startWebhookRetry runs webhook-retry for the delivery saved at path without
+// waiting for it.
+var startWebhookRetry = func(path string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(self, "webhook-retry", path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+func webhookRetryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "webhook-retry <file>",
+		Short:  "retry a failed webhook delivery",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWebhookRetry(args[0], webhookBackoff)
+		},
+	}
+}
+
+// runWebhookRetry makes the remaining attempts at the delivery saved at path,
+// waiting backoff before the first and twice as long before each one after.
+// Nobody is watching, so the outcome is only logged.
+func runWebhookRetry(path string, backoff time.Duration) error {
+	var d webhookDelivery
+	err := readJSONFile(path, &d)
+	os.Remove(path)
+	if err != nil {
+		return err
+	}
+	if d.URL == "" {
+		return nil
+	}
+
+	client := newHTTPClient(webhookTimeout)
+	for attempt := 2; ; attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		retry, err := d.post(client)
+		if err == nil {
+			debugf("webhook %s delivered on attempt %d", d.URL, attempt)
+			return nil
+		}
+		if !retry || attempt == webhookAttempts {
+			debugf("webhook %s failed: %s", d.URL, err)
+			return err
+		}
+	}
+}
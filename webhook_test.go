@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// webhookRequest is a request received by a fake webhook target.
+type webhookRequest struct {
+	body      []byte
+	signature string
+}
+
+// newWebhookServer starts a webhook target that answers with codes in turn,
+// then 200, and sends what it receives on the returned channel.
+func newWebhookServer(t *testing.T, codes ...int) (*httptest.Server, chan webhookRequest) {
+	t.Helper()
+	received := make(chan webhookRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("got Content-Type %q", ct)
+		}
+		received <- webhookRequest{body: body, signature: r.Header.Get("X-Gh-User-Status-Signature")}
+		if len(codes) > 0 {
+			w.WriteHeader(codes[0])
+			codes = codes[1:]
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func writeWebhookConfig(t *testing.T, targets ...webhookTarget) {
+	t.Helper()
+	path, err := configPath("config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONFile(path, config{Webhooks: targets}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetNotifiesWebhooks(t *testing.T) {
+	setupTest(t, "get_viewer", "set_pizza")
+	srv, received := newWebhookServer(t)
+	writeWebhookConfig(t, webhookTarget{URL: srv.URL, Secret: "hunter2"})
+
+	if _, err := runCommand(t, "set", "-e", "pizza", "lunch"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var req webhookRequest
+	select {
+	case req = <-received:
+	default:
+		t.Fatal("expected the webhook to be called")
+	}
+
+	mac := hmac.New(sha256.New, []byte("hunter2"))
+	mac.Write(req.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.signature != want {
+		t.Errorf("got signature %q, want %q", req.signature, want)
+	}
+
+	var payload struct {
+		Event string
+		Login string
+		Old   struct{ Message, Emoji string }
+		New   struct{ Message, Emoji string }
+	}
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("failed to decode payload %s: %s", req.body, err)
+	}
+	if payload.Event != "status.changed" || payload.Login != "monalisa" {
+		t.Errorf("unexpected payload %s", req.body)
+	}
+	if payload.Old.Message != "on vacation" || payload.New.Message != "lunch" || payload.New.Emoji != ":pizza:" {
+		t.Errorf("unexpected statuses in payload %s", req.body)
+	}
+}
+
+func TestClearNotifiesWebhookTemplate(t *testing.T) {
+	setupTest(t, "get_viewer", "clear")
+	srv, received := newWebhookServer(t)
+	writeWebhookConfig(t, webhookTarget{URL: srv.URL, Template: `{"text": {{json .Event}}, "was": {{json .Old.Message}}}`})
+
+	if _, err := runCommand(t, "clear"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	req := <-received
+	if want := `{"text": "status.cleared", "was": "on vacation"}`; string(req.body) != want {
+		t.Errorf("got body %s, want %s", req.body, want)
+	}
+	if req.signature != "" {
+		t.Errorf("expected no signature without a secret, got %q", req.signature)
+	}
+}
+
+func TestDeliverWebhookRetriesInBackground(t *testing.T) {
+	setupTest(t)
+	srv, received := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	var queued []string
+	prev := startWebhookRetry
+	startWebhookRetry = func(path string) error {
+		queued = append(queued, path)
+		return nil
+	}
+	t.Cleanup(func() { startWebhookRetry = prev })
+
+	start := time.Now()
+	deliverWebhook(newHTTPClient(webhookTimeout), webhookTarget{URL: srv.URL, Secret: "hunter2"},
+		webhookPayload{Event: "status.cleared", Login: "monalisa"})
+	if d := time.Since(start); d >= webhookBackoff {
+		t.Errorf("expected not to wait for a retry, took %s", d)
+	}
+	first := <-received
+	if len(queued) != 1 {
+		t.Fatalf("expected a retry to be queued, got %q", queued)
+	}
+
+	if err := runWebhookRetry(queued[0], time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected two more attempts, got %d", len(received))
+	}
+	for i := 0; i < 2; i++ {
+		req := <-received
+		if string(req.body) != string(first.body) || req.signature != first.signature {
+			t.Errorf("expected the same signed body to be retried, got %s %q", req.body, req.signature)
+		}
+	}
+	if _, err := os.Stat(queued[0]); !os.IsNotExist(err) {
+		t.Errorf("expected the saved delivery to be removed, got %v", err)
+	}
+}
+
+func TestDeliverWebhookDoesNotRetryClientErrors(t *testing.T) {
+	setupTest(t)
+	srv, received := newWebhookServer(t, http.StatusNotFound)
+
+	prev := startWebhookRetry
+	startWebhookRetry = func(path string) error {
+		t.Errorf("expected no retry for a client error")
+		return nil
+	}
+	t.Cleanup(func() { startWebhookRetry = prev })
+
+	deliverWebhook(newHTTPClient(webhookTimeout), webhookTarget{URL: srv.URL}, webhookPayload{Event: "status.cleared"})
+	if len(received) != 1 {
+		t.Errorf("expected one attempt, got %d", len(received))
+	}
+}
+
+func TestSetWithoutWebhooksSkipsStatusLookup(t *testing.T) {
+	f := setupTest(t, "set_pizza")
+	writeWebhookConfig(t)
+
+	if _, err := runCommand(t, "set", "-e", "pizza", "lunch"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected only the status to be set, got %q", f.calls)
+	}
+}